@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"github.com/resonatehq/durable-promise-test-harness/cmd/compare"
 	"github.com/resonatehq/durable-promise-test-harness/cmd/verify"
 	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
 	"github.com/spf13/cobra"
@@ -19,6 +20,12 @@ func New() *cobra.Command {
 				verify.NewCmd(),
 			},
 		},
+		{
+			Message: "analysis commands",
+			Commands: []*cobra.Command{
+				compare.NewCmd(),
+			},
+		},
 	}
 
 	groups.Add(rootCmd)
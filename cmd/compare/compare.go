@@ -0,0 +1,70 @@
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/artifacts"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/checker"
+	"github.com/spf13/cobra"
+)
+
+var (
+	out string
+
+	latencyTolerance    float64
+	throughputTolerance float64
+	statusTolerance     float64
+)
+
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "compare <runA> <runB>",
+		Short:   "Compare the results of two runs and flag regressions",
+		Example: "harness compare test/results/01-02-2024_10-00-00 test/results/01-03-2024_10-00-00",
+		Args:    cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			dirA, dirB := resolveRun(args[0]), resolveRun(args[1])
+
+			a, err := checker.LoadResult(dirA)
+			if err != nil {
+				log.Fatal(err)
+			}
+			b, err := checker.LoadResult(dirB)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			comparison := checker.Compare(dirA, a, dirB, b, checker.Tolerances{
+				Latency:     latencyTolerance / 100,
+				Throughput:  throughputTolerance / 100,
+				StatusCodes: statusTolerance / 100,
+			})
+
+			fmt.Println(comparison)
+
+			if n := len(comparison.Regressions()); n > 0 {
+				log.Fatal(fmt.Errorf("found %d regressions", n))
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&out, "out", "o", artifacts.DefaultDir, "directory runs are read from when given by name")
+	cmd.Flags().Float64Var(&latencyTolerance, "latency-tolerance", 10, "allowed increase of any latency metric in percent")
+	cmd.Flags().Float64Var(&throughputTolerance, "throughput-tolerance", 10, "allowed decrease in throughput in percent")
+	cmd.Flags().Float64Var(&statusTolerance, "status-tolerance", 5, "allowed decrease in the share of any 2xx status code, or increase in the share of any other code, in percentage points")
+
+	return cmd
+}
+
+// resolveRun accepts either a path to a results directory or the name of a
+// run under the output directory.
+func resolveRun(run string) string {
+	if _, err := os.Stat(run); errors.Is(err, os.ErrNotExist) {
+		return filepath.Join(out, run)
+	}
+	return run
+}
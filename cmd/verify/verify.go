@@ -2,7 +2,10 @@ package verify
 
 import (
 	"log"
+	"time"
 
+	"github.com/resonatehq/durable-promise-test-harness/pkg/artifacts"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/checker"
 	"github.com/resonatehq/durable-promise-test-harness/pkg/simulator"
 	"github.com/spf13/cobra"
 )
@@ -11,6 +14,56 @@ var (
 	addr     string
 	clients  int
 	requests int
+	rounds   int
+	seed     int64
+	out      string
+	runName  string
+
+	namespace      string
+	checkNamespace bool
+	bootstrap      bool
+
+	clockHook string
+	ticks     int
+	tick      time.Duration
+
+	username           string
+	password           string
+	token              string
+	headers            []string
+	caCert             string
+	clientCert         string
+	clientKey          string
+	insecureSkipVerify bool
+
+	capture bool
+
+	fuzz float64
+
+	workload string
+	skew     float64
+	hotKeys  int
+	stray    float64
+
+	uniqueValues   bool
+	payloadSize    int
+	payloadDist    string
+	payloadMax     int
+	payloadBinary  bool
+	payloadHeaders int
+
+	slos    []string
+	sloFile string
+
+	engines     []string
+	consistency []string
+
+	metricsAddr string
+
+	traceExporter string
+	traceEndpoint string
+	traceInsecure bool
+	traceFile     string
 )
 
 func NewCmd() *cobra.Command {
@@ -19,10 +72,119 @@ func NewCmd() *cobra.Command {
 		Short:   "Run multiple concurrent clients to verify for linearizable consistency and performance",
 		Example: "harness verify -a http://0.0.0.0:8001/ -r 1000 -c 10",
 		Run: func(cmd *cobra.Command, args []string) {
+			thresholds, err := checker.ParseThresholds(slos, sloFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			checkers, err := checker.ParseEngines(engines)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			models, err := checker.ParseConsistencyModels(consistency)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			httpHeaders, err := simulator.ParseHeaders(headers)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			payload := simulator.PayloadConfig{
+				Distribution: payloadDist,
+				Size:         payloadSize,
+				Max:          payloadMax,
+				Binary:       payloadBinary,
+				Headers:      payloadHeaders,
+			}
+			if err := payload.Validate(); err != nil {
+				log.Fatal(err)
+			}
+
+			if namespace == "" {
+				namespace = simulator.NewNamespace(seed)
+			}
+			if err := simulator.ValidateNamespace(namespace); err != nil {
+				log.Fatal(err)
+			}
+
+			if err := artifacts.ValidateName(runName); err != nil {
+				log.Fatal(err)
+			}
+
+			if rounds < 1 {
+				log.Fatalf("rounds must be at least 1, got %d", rounds)
+			}
+
+			clock := simulator.TickConfig{Ticks: ticks, Tick: tick}
+			if clockHook != "" {
+				if err := clock.Validate(); err != nil {
+					log.Fatal(err)
+				}
+			}
+
+			if fuzz < 0 || fuzz > 1 {
+				log.Fatalf("fuzz must be between 0 and 1, got %v", fuzz)
+			}
+
+			load := simulator.WorkloadConfig{
+				Mode:    workload,
+				Skew:    skew,
+				HotKeys: hotKeys,
+				Stray:   stray,
+			}
+			if err := load.Validate(); err != nil {
+				log.Fatal(err)
+			}
+			if load.Mode == simulator.RaceWorkload && fuzz > 0 {
+				log.Fatal("fuzz can not be combined with the race workload")
+			}
+
+			var tracing *simulator.TracingConfig
+			if traceExporter != "" {
+				tracing = &simulator.TracingConfig{
+					Exporter: traceExporter,
+					Endpoint: traceEndpoint,
+					Insecure: traceInsecure,
+					File:     traceFile,
+				}
+			}
+
 			sim := simulator.NewSimulation(&simulator.SimulationConfig{
-				Addr:        addr,
-				NumClients:  clients,
-				NumRequests: requests,
+				Addr:           addr,
+				NumClients:     clients,
+				NumRequests:    requests,
+				Rounds:         rounds,
+				Namespace:      namespace,
+				CheckNamespace: checkNamespace,
+				Bootstrap:      bootstrap,
+				ClockHook:      clockHook,
+				Ticks:          clock,
+				Seed:           seed,
+				Out:            out,
+				RunName:        runName,
+				UniqueValues:   uniqueValues || payloadSize > 0,
+				Payload:        payload,
+				Fuzz:           fuzz,
+				Workload:       load,
+				HTTP: &simulator.HTTPConfig{
+					Username:           username,
+					Password:           password,
+					BearerToken:        token,
+					Headers:            httpHeaders,
+					CACert:             caCert,
+					ClientCert:         clientCert,
+					ClientKey:          clientKey,
+					InsecureSkipVerify: insecureSkipVerify,
+				},
+				Capture:     capture,
+				Thresholds:  thresholds,
+				Engines:     checkers,
+				Models:      models,
+				MetricsAddr: metricsAddr,
+				Tracing:     tracing,
 			})
 
 			if err := sim.Run(); err != nil {
@@ -33,7 +195,48 @@ func NewCmd() *cobra.Command {
 
 	cmd.Flags().StringVarP(&addr, "addr", "a", "http://0.0.0.0:8001/", "address of durable promise server")
 	cmd.Flags().IntVarP(&clients, "clients", "c", 1, "number of clients")
-	cmd.Flags().IntVarP(&requests, "requests", "r", 1, "number of requests per client")
+	cmd.Flags().IntVarP(&requests, "requests", "r", 1, "number of requests per client and round")
+	cmd.Flags().StringVar(&namespace, "namespace", "", "prefix of every promise id and search of the run, must not contain '.', '*' or '/', defaults to a new namespace per run, or one derived from --seed")
+	cmd.Flags().BoolVar(&checkNamespace, "check-namespace", false, "warn before the run if the namespace already holds promises")
+	cmd.Flags().BoolVar(&bootstrap, "bootstrap", false, "seed the model with a snapshot of the promises already in the namespace, for repeated runs with --namespace")
+	cmd.Flags().IntVar(&rounds, "rounds", 1, "number of rounds, clients wait for each other between rounds and every round is checked on its own")
+	cmd.Flags().StringVar(&clockHook, "clock-hook", "", "url of the test clock of the server, GET returns and POST sets {\"time\": <unix ms>}, the clock then moves in ticks and promises time out on them")
+	cmd.Flags().IntVar(&ticks, "ticks", simulator.DefaultTicks, "number of ticks per round with --clock-hook, clients wait for each other between ticks")
+	cmd.Flags().DurationVar(&tick, "tick", simulator.DefaultTick, "server time the clock moves forward by between ticks with --clock-hook")
+	cmd.Flags().Int64Var(&seed, "seed", 0, "seed of the generator, runs with the same seed and flags send the same operations")
+	cmd.Flags().StringVarP(&out, "out", "o", artifacts.DefaultDir, "directory runs are written to")
+	cmd.Flags().StringVar(&runName, "run-name", "", "name of the run directory within --out, must not contain a path separator or '..', defaults to the start time of the run")
+
+	cmd.Flags().Float64Var(&fuzz, "fuzz", 0, "fraction of operations replaced by malformed or edge case inputs, e.g. 0.1")
+	cmd.Flags().StringVar(&workload, "workload", simulator.UniformWorkload, "how operations pick promise ids, one of: uniform, hot-key (zipf skewed), race (every client contends on --hot-keys fresh ids per round), lifecycle (every client walks its own promises through create, get, complete and read)")
+	cmd.Flags().Float64Var(&skew, "skew", simulator.DefaultSkew, "zipf exponent of the hot-key workload, must be greater than 1")
+	cmd.Flags().IntVar(&hotKeys, "hot-keys", simulator.DefaultHotKeys, "number of ids every client contends on per round of the race workload")
+	cmd.Flags().Float64Var(&stray, "stray", simulator.DefaultStray, "probability a step of the lifecycle workload is an invalid transition, e.g. completing a promise twice")
+	cmd.Flags().BoolVar(&uniqueValues, "unique-values", false, "make every written value encode the operation and client that wrote it")
+	cmd.Flags().IntVar(&payloadSize, "payload-size", 0, "bytes of filler added to every unique value, implies --unique-values")
+	cmd.Flags().StringVar(&payloadDist, "payload-dist", simulator.FixedPayload, "distribution of payload sizes, one of: fixed, uniform (up to --payload-size), heavy-tail (from --payload-size up to --payload-max)")
+	cmd.Flags().IntVar(&payloadMax, "payload-max", simulator.DefaultPayloadMax, "largest heavy tailed payload in bytes")
+	cmd.Flags().BoolVar(&payloadBinary, "payload-binary", false, "fill payloads with random bytes instead of alphanumerics")
+	cmd.Flags().IntVar(&payloadHeaders, "payload-headers", 0, "maximum number of random headers set on every param and value")
+
+	cmd.Flags().StringVar(&username, "username", "", "basic auth username")
+	cmd.Flags().StringVar(&password, "password", "", "basic auth password")
+	cmd.Flags().StringVar(&token, "token", "", "bearer token")
+	cmd.Flags().StringArrayVarP(&headers, "header", "H", []string{}, "extra request header as key=value or key: value, may be repeated")
+	cmd.Flags().StringVar(&caCert, "cacert", "", "path to a pem encoded ca bundle used to verify the server")
+	cmd.Flags().StringVar(&clientCert, "cert", "", "path to a pem encoded client certificate for mtls")
+	cmd.Flags().StringVar(&clientKey, "key", "", "path to a pem encoded client private key for mtls")
+	cmd.Flags().BoolVar(&insecureSkipVerify, "insecure", false, "skip verification of the server certificate")
+	cmd.Flags().StringArrayVar(&slos, "slo", []string{}, "performance threshold such as 'GET.p99<50ms', 'error_rate<0.1%' or 'rps>=500', may be repeated")
+	cmd.Flags().StringVar(&sloFile, "slo-file", "", "path to a file with one performance threshold per line")
+	cmd.Flags().StringSliceVar(&engines, "checker", []string{string(checker.Porcupine)}, "engines the history is checked with, any of: all, porcupine, graph")
+	cmd.Flags().StringSliceVar(&consistency, "consistency", []string{string(checker.Linearizable)}, "consistency models the history must satisfy, any of: all, linearizable, sequential, read-your-writes, monotonic-reads, monotonic-writes")
+	cmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "address to serve prometheus metrics on during the run, e.g. :9090")
+	cmd.Flags().StringVar(&traceExporter, "trace-exporter", "", "export a span per operation, one of: otlp, file")
+	cmd.Flags().StringVar(&traceEndpoint, "trace-endpoint", "localhost:4318", "host:port of the otlp http collector")
+	cmd.Flags().BoolVar(&traceInsecure, "trace-insecure", false, "connect to the otlp collector without tls")
+	cmd.Flags().StringVar(&traceFile, "trace-file", "", "path spans are written to by the file exporter, defaults to traces.json in the run directory")
+	cmd.Flags().BoolVar(&capture, "capture", false, "record every http exchange to exchanges.har in the results directory")
 
 	return cmd
 }
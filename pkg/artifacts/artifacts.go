@@ -0,0 +1,147 @@
+package artifacts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDir is where runs are written to unless told otherwise.
+const DefaultDir = "test/results"
+
+// manifestName is the file that lists every artifact of a run.
+const manifestName = "manifest.json"
+
+// Run owns the directory all artifacts of a single run are written to. It is
+// created once and handed to every writer, so all files of a run end up in
+// the same place and are listed in its manifest.
+type Run struct {
+	Name    string
+	Dir     string
+	Started time.Time
+
+	mu    sync.Mutex
+	files map[string]string
+}
+
+// ValidateName checks a run name is a single directory within out, so a name
+// such as '../x' or an absolute path can not write outside of it.
+func ValidateName(name string) error {
+	if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") || filepath.IsAbs(name) {
+		return fmt.Errorf("run name '%s' must not contain a path separator or '..'", name)
+	}
+	return nil
+}
+
+// New creates the directory of a run under out. If name is empty the run is
+// named after its start time.
+func New(out, name string) (*Run, error) {
+	started := time.Now()
+	if out == "" {
+		out = DefaultDir
+	}
+	if name == "" {
+		name = started.Format("01-02-2006_15-04-05")
+	}
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(out, name)
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("run directory '%s' already exists and is not empty", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &Run{
+		Name:    name,
+		Dir:     dir,
+		Started: started,
+		files:   map[string]string{},
+	}, nil
+}
+
+// Path registers an artifact and returns the path it should be written to.
+func (r *Run) Path(name, description string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[name] = description
+	return filepath.Join(r.Dir, name)
+}
+
+// WriteString writes and registers an artifact.
+func (r *Run) WriteString(name, description, content string) error {
+	path := r.Path(name, description)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// Create opens and registers an artifact that is written incrementally.
+func (r *Run) Create(name, description string) (*os.File, error) {
+	path := r.Path(name, description)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
+
+// File is an entry of the manifest.
+type File struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Size        int64  `json:"size"`
+}
+
+// Manifest lists every artifact produced by a run.
+type Manifest struct {
+	Name     string    `json:"name"`
+	Dir      string    `json:"dir"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Files    []File    `json:"files"`
+}
+
+// WriteManifest writes manifest.json listing every registered artifact that exists on disk.
+func (r *Run) WriteManifest() error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	r.mu.Unlock()
+	sort.Strings(names)
+
+	manifest := Manifest{
+		Name:     r.Name,
+		Dir:      r.Dir,
+		Started:  r.Started,
+		Finished: time.Now(),
+		Files:    make([]File, 0, len(names)),
+	}
+	for _, name := range names {
+		info, err := os.Stat(filepath.Join(r.Dir, name))
+		if err != nil {
+			continue
+		}
+		manifest.Files = append(manifest.Files, File{
+			Name:        name,
+			Description: r.files[name],
+			Size:        info.Size(),
+		})
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.Dir, manifestName), b, 0644)
+}
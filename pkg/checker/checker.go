@@ -1,55 +1,274 @@
 package checker
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/anishathalye/porcupine"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/artifacts"
 	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
-	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
 )
 
 // Checker validates that a history is correct with respect to some model.
 type Checker struct {
 	*Visualizer
+
+	// Thresholds are performance objectives that fail the check when not met.
+	Thresholds []Threshold
+
+	// Engines check the history for linearizability, defaults to porcupine.
+	Engines []Engine
+
+	// Models are the consistency models the history must satisfy, defaults to
+	// linearizability. Linearizability is reported whenever porcupine is used.
+	Models []ConsistencyModel
+
+	// Progress is optional, when set it is notified as checks start and finish.
+	Progress Progress
+
+	// Config describes the run in the report.
+	Config map[string]string
+
+	// Initial is the state of the server before the run, the model starts
+	// from an empty server when it is nil.
+	Initial State
+
+	// Probes are requests sent before the run, they add to the samples the
+	// offset of the server clock is estimated from.
+	Probes []store.Operation
+}
+
+// Progress is notified as the checker works through a history.
+type Progress interface {
+	CheckStarted(ops int)
+	CheckFinished(pass bool, elapsed time.Duration)
 }
 
+// checkTimeout bounds the search of every consistency check.
+const checkTimeout = 1 * time.Hour
+
 // Creates a new Checker with reasonable defaults.
 func NewChecker() *Checker {
 	return &Checker{
 		Visualizer: NewVisualizer(),
+		Engines:    []Engine{Porcupine},
+		Models:     []ConsistencyModel{Linearizable},
+	}
+}
+
+// Engine is a way of checking a history for linearizability.
+type Engine string
+
+const (
+	// Porcupine searches for a linearization of the history, it is exact
+	// but exponential in the worst case.
+	Porcupine Engine = "porcupine"
+	// Graph builds a dependency graph from the values read and written and
+	// reports named anomalies, it scales to histories far larger than
+	// porcupine can handle but only finds what the values reveal.
+	Graph Engine = "graph"
+)
+
+// Engines lists every engine.
+var Engines = []Engine{Porcupine, Graph}
+
+// ParseEngines parses engine names, "all" selects every engine.
+func ParseEngines(names []string) ([]Engine, error) {
+	engines := []Engine{}
+	seen := map[Engine]bool{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "all" {
+			return Engines, nil
+		}
+		e := Engine(name)
+		if e != Porcupine && e != Graph {
+			return nil, fmt.Errorf("unknown checker '%s', expected one of: all, %s, %s", name, Porcupine, Graph)
+		}
+		if !seen[e] {
+			seen[e] = true
+			engines = append(engines, e)
+		}
 	}
+	return engines, nil
 }
 
-// Check verifies the history is linearizably consistent with respect to the model.
-func (c *Checker) Check(history []store.Operation) error {
-	model, events := newPorcupineModel(), makePorcupineEvents(history)
+// Check verifies the history is linearizably consistent with respect to the model
+// and writes its results to the artifacts of the run.
+func (c *Checker) Check(history []store.Operation, run *artifacts.Run) error {
+	start := time.Now()
+	if c.Progress != nil {
+		c.Progress.CheckStarted(len(history))
+	}
+
+	result := &Result{
+		Engines:     c.Engines,
+		Models:      c.Models,
+		Performance: NewPerformance(history),
+		Clock:       EstimateClock(append(append([]store.Operation{}, c.Probes...), history...)),
+	}
+	config := modelConfig{initial: c.Initial, clock: result.Clock}
 
-	var pass bool
+	rounds := splitRounds(history)
+	var rendered string
+	var shownFailure bool
+	for i, rd := range rounds {
+		roundResult, visualization, err := c.checkRound(result, config, rd, run, len(rounds) > 1)
+		if err != nil {
+			return err
+		}
+		result.add(roundResult, i == 0)
+		if len(rounds) > 1 {
+			result.Rounds = append(result.Rounds, *roundResult)
+		}
 
-	res, info := porcupine.CheckEventsVerbose(model, events, 1*time.Hour)
-	if res != porcupine.Illegal {
-		pass = true
+		// the report shows the first failing round, or the first round if all pass
+		if i == 0 || (!roundResult.Pass && !shownFailure) {
+			rendered = visualization
+			shownFailure = !roundResult.Pass
+		}
 	}
+	result.Thresholds, result.ThresholdsPass = EvaluateThresholds(c.Thresholds, result.Performance)
 
-	today := time.Now().Format("01-02-2006_15-04-05")
+	if c.Progress != nil {
+		c.Progress.CheckFinished(result.Err() == nil, time.Since(start))
+	}
 
-	filePath := fmt.Sprintf("test/results/%s/visualization.html", today)
-	err := utils.WriteStringToFile("", filePath)
-	if err != nil {
+	if err := c.Summary(result, run, history); err != nil {
 		return err
 	}
 
-	err = porcupine.VisualizePath(model, info, filePath)
-	if err != nil {
+	report := c.Report(result, c.Config, rendered, history)
+	if err := run.WriteString("index.html", "self contained report of the run", report); err != nil {
 		return err
 	}
 
-	c.Summary(pass, today, history)
+	return result.Err()
+}
+
+// checkRound checks the operations of a round for correctness and returns the
+// porcupine visualization of the round, if porcupine was used.
+func (c *Checker) checkRound(result *Result, config modelConfig, rd round, run *artifacts.Run, numbered bool) (*RoundResult, string, error) {
+	history := rd.ops
+	res := &RoundResult{Round: rd.number, Operations: len(history)}
+
+	var rendered bytes.Buffer
+	if result.uses(Porcupine) {
+		model, events := newPorcupineModel(config), makePorcupineEvents(history)
+
+		out, info := porcupine.CheckEventsVerbose(model, events, checkTimeout)
+		res.Linearizable = out != porcupine.Illegal
+
+		if err := porcupine.Visualize(model, info, &rendered); err != nil {
+			return nil, "", err
+		}
+
+		name, desc := "visualization.html", "porcupine visualization of the linearization"
+		if numbered {
+			name = fmt.Sprintf("visualization-round-%d.html", rd.number)
+			desc = fmt.Sprintf("porcupine visualization of the linearization of round %d", rd.number)
+		}
+		if err := run.WriteString(name, desc, rendered.String()); err != nil {
+			return nil, "", err
+		}
+
+		if !res.Linearizable {
+			res.Explanation = explain(info, events, history, config)
+			if res.Explanation != nil && numbered {
+				res.Explanation.Round = rd.number
+			}
+		}
+	}
+	if result.uses(Graph) {
+		res.Graph = checkGraph(history)
+	}
+	for _, m := range c.Models {
+		if m != Linearizable {
+			res.Consistency = append(res.Consistency, checkConsistency(m, history, config, checkTimeout))
+		}
+	}
+
+	res.judge(result)
+	return res, rendered.String(), nil
+}
+
+// Result is the verdict of a check.
+type Result struct {
+	// Engines are the engines the history was checked with.
+	Engines      []Engine     `json:"engines,omitempty"`
+	Linearizable bool         `json:"linearizable"`
+	Explanation  *Explanation `json:"explanation,omitempty"`
+	Graph        *GraphResult `json:"graph,omitempty"`
+	// Models are the consistency models the run was required to satisfy.
+	Models      []ConsistencyModel  `json:"models,omitempty"`
+	Consistency []ConsistencyResult `json:"consistency,omitempty"`
+	// Rounds are the verdicts of every round of a run with several rounds.
+	Rounds []RoundResult `json:"rounds,omitempty"`
+	// Clock is the estimated offset of the server clock, timeouts are
+	// checked against it.
+	Clock          *Clock            `json:"clock,omitempty"`
+	Performance    *Performance      `json:"performance"`
+	Thresholds     []ThresholdResult `json:"thresholds"`
+	ThresholdsPass bool              `json:"thresholdsPass"`
+}
+
+// Err returns an error describing every failed check, or nil if the run passed.
+func (r *Result) Err() error {
+	errs := []string{}
+	if r.uses(Porcupine) && !r.Linearizable && r.requires(Linearizable) {
+		errs = append(errs, "history is not linearizable, check results for more details")
+	}
+	if r.Graph != nil && !r.Graph.Pass && r.requires(Linearizable) {
+		errs = append(errs, fmt.Sprintf("history has %d anomalies, check results for more details", len(r.Graph.Anomalies)))
+	}
+	for _, c := range r.Consistency {
+		if !c.Pass && r.requires(c.Model) {
+			errs = append(errs, fmt.Sprintf("history violates %s, check results for more details", strings.ToLower(c.Model.String())))
+		}
+	}
+	if !r.ThresholdsPass {
+		failed := []string{}
+		for _, t := range r.Thresholds {
+			if !t.Pass {
+				failed = append(failed, t.Message)
+			}
+		}
+		errs = append(errs, fmt.Sprintf("performance thresholds not met: %s", strings.Join(failed, "; ")))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, ", "))
+}
+
+// requires reports whether the run had to satisfy the model, results written
+// before models were selectable only required linearizability.
+func (r *Result) requires(model ConsistencyModel) bool {
+	if len(r.Models) == 0 {
+		return model == Linearizable
+	}
+	for _, m := range r.Models {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
 
-	if !pass {
-		return errors.New("history is not linearizable, check results for more details")
+// uses reports whether the history was checked with the engine, results
+// written before engines were selectable were only checked with porcupine.
+func (r *Result) uses(engine Engine) bool {
+	if len(r.Engines) == 0 {
+		return engine == Porcupine
+	}
+	for _, e := range r.Engines {
+		if e == engine {
+			return true
+		}
 	}
-	return nil
+	return false
 }
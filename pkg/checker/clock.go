@@ -0,0 +1,176 @@
+package checker
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+// Clock is an estimate of the offset of the server clock from the client
+// clock, the offset is positive when the server clock is ahead.
+type Clock struct {
+	// Offset is the middle of the bounds.
+	Offset time.Duration `json:"offset"`
+	// Lower and Upper bound the offset.
+	Lower time.Duration `json:"lower"`
+	Upper time.Duration `json:"upper"`
+	// Samples is the number of observations the estimate is made from.
+	Samples int `json:"samples"`
+	// Drift is set when samples contradict each other, e.g. because one of
+	// the clocks was adjusted during the run. The bounds then span every
+	// sample instead of their intersection.
+	Drift bool `json:"drift,omitempty"`
+}
+
+const (
+	// dateResolution is the resolution of the http Date header.
+	dateResolution = time.Second
+
+	// stampResolution is the resolution of createdOn and completedOn.
+	stampResolution = time.Millisecond
+)
+
+// EstimateClock estimates the offset of the server clock from the Date header
+// of every response, and from the createdOn and completedOn of every promise
+// a request created or completed. The server reads its clock between the call
+// and the return of the request, so every sample bounds the offset from both
+// sides. It returns nil when the history has no samples.
+func EstimateClock(history []store.Operation) *Clock {
+	dates, stamps := []bound{}, []bound{}
+	for _, op := range history {
+		// a controlled clock says nothing about the offset of the real one
+		if !op.ServerTime.IsZero() {
+			continue
+		}
+		if !op.ServerDate.IsZero() {
+			dates = append(dates, newBound(op, op.ServerDate, dateResolution))
+		}
+		if at, ok := stamp(op); ok {
+			stamps = append(stamps, newBound(op, at, stampResolution))
+		}
+	}
+
+	// the Date header is set by the http server rather than the store, so
+	// timestamps that contradict it are left out and rejected by the model
+	if c := estimate(dates); c != nil && !c.Drift {
+		kept := stamps[:0]
+		for _, b := range stamps {
+			if b.lower <= c.Upper && b.upper >= c.Lower {
+				kept = append(kept, b)
+			}
+		}
+		stamps = kept
+	}
+
+	return estimate(append(dates, stamps...))
+}
+
+// bound is the range of offsets a single sample allows.
+type bound struct {
+	lower, upper time.Duration
+}
+
+func newBound(op store.Operation, at time.Time, resolution time.Duration) bound {
+	return bound{lower: at.Sub(op.ReturnEvent), upper: at.Add(resolution).Sub(op.CallEvent)}
+}
+
+// estimate intersects the bounds, or spans them if they do not intersect.
+func estimate(bounds []bound) *Clock {
+	if len(bounds) == 0 {
+		return nil
+	}
+
+	c := &Clock{Lower: bounds[0].lower, Upper: bounds[0].upper, Samples: len(bounds)}
+	lowest, highest := c.Lower, c.Upper
+	for _, b := range bounds[1:] {
+		c.Lower, c.Upper = max(c.Lower, b.lower), min(c.Upper, b.upper)
+		lowest, highest = min(lowest, b.lower), max(highest, b.upper)
+	}
+	if c.Lower > c.Upper {
+		c.Drift = true
+		c.Lower, c.Upper = lowest, highest
+	}
+	c.Offset = c.Lower + (c.Upper-c.Lower)/2
+	return c
+}
+
+// stamp returns the server time a request created or completed a promise at.
+// Idempotent requests return the time of an earlier request and are skipped.
+func stamp(op store.Operation) (time.Time, bool) {
+	p, ok := op.Output.(*openapi.Promise)
+	if op.Status != store.Ok || op.Code != http.StatusCreated || !ok || p == nil {
+		return time.Time{}, false
+	}
+
+	var at *int
+	switch op.API {
+	case store.Create:
+		at = p.CreatedOn
+	case store.Resolve, store.Reject, store.Cancel:
+		at = p.CompletedOn
+	}
+	if at == nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(*at)), true
+}
+
+func (c *Clock) String() string {
+	if c == nil {
+		return "unknown"
+	}
+	s := fmt.Sprintf("%v (between %v and %v, %d samples)",
+		c.Offset.Round(time.Microsecond), c.Lower.Round(time.Microsecond), c.Upper.Round(time.Microsecond), c.Samples)
+	if c.Drift {
+		s += ", samples disagree"
+	}
+	return s
+}
+
+// driftWarning explains why timing checks are inconclusive when the samples
+// disagree: timeouts and timestamps are then checked against every offset any
+// sample allows, and a wrong one may go unnoticed.
+const driftWarning = "UNKNOWN, server clock samples disagree, timeouts and timestamps were only checked loosely"
+
+// window is the span of server time, in milliseconds, in which an operation
+// may have taken effect.
+type window struct {
+	earliest, latest int64
+}
+
+// window returns the server time of an operation from its call and return.
+// Without an estimate the operation may have taken effect at any time, with
+// a controlled clock it took effect at the time the clock was set to.
+func (c *Clock) window(req, resp event) window {
+	if !req.serverTime.IsZero() {
+		at := req.serverTime.UnixMilli()
+		return window{earliest: at, latest: at}
+	}
+	if c == nil {
+		return window{math.MinInt64, math.MaxInt64}
+	}
+	return window{
+		earliest: req.time.Add(c.Lower).UnixMilli(),
+		latest:   resp.time.Add(c.Upper).UnixMilli(),
+	}
+}
+
+// passed reports whether a timeout has passed on the server.
+func (w window) passed(timeout int64) bool {
+	return timeout <= w.earliest
+}
+
+// contains reports whether the server clock could have read the given time,
+// in milliseconds, during the operation.
+func (w window) contains(at int64) bool {
+	return w.earliest <= at && at <= w.latest
+}
+
+// mayHavePassed reports whether a timeout could have passed on the server.
+func (w window) mayHavePassed(timeout int64) bool {
+	return timeout <= w.latest
+}
@@ -0,0 +1,130 @@
+package checker
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
+)
+
+// TestEstimateClock checks the offset bounds estimated from Date headers and
+// timestamps, all times are milliseconds after fuzzBase.
+func TestEstimateClock(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []store.Operation
+		want    *Clock
+	}{
+		{
+			name: "no samples",
+			want: nil,
+		},
+		{
+			// [800ms, 2s] and [1.4s, 2.5s]
+			name: "intersect",
+			history: []store.Operation{
+				clockDate(0, 200, 1000),
+				clockDate(500, 600, 2000),
+			},
+			want: &Clock{Offset: 1700 * time.Millisecond, Lower: 1400 * time.Millisecond, Upper: 2 * time.Second, Samples: 2},
+		},
+		{
+			// [800ms, 2s] and [-5.1s, -4s] span [-5.1s, 2s]
+			name: "drift",
+			history: []store.Operation{
+				clockDate(0, 200, 1000),
+				clockDate(0, 100, -5000),
+			},
+			want: &Clock{Offset: -1550 * time.Millisecond, Lower: -5100 * time.Millisecond, Upper: 2 * time.Second, Samples: 2, Drift: true},
+		},
+		{
+			// the Date header allows [800ms, 2s], the first createdOn
+			// [1.1s, 1.201s] and the second, an hour off, is left out
+			name: "stamps filtered by dates",
+			history: []store.Operation{
+				clockDate(0, 200, 1000),
+				clockCreate(300, 400, 1500),
+				clockCreate(500, 600, int64(time.Hour.Milliseconds())),
+			},
+			want: &Clock{Offset: 1150500 * time.Microsecond, Lower: 1100 * time.Millisecond, Upper: 1201 * time.Millisecond, Samples: 2},
+		},
+		{
+			name: "controlled clock",
+			history: func() []store.Operation {
+				op := clockDate(0, 200, 1000)
+				op.ServerTime = fuzzBase
+				return []store.Operation{op}
+			}(),
+			want: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := EstimateClock(test.history)
+			if (got == nil) != (test.want == nil) || got != nil && *got != *test.want {
+				t.Fatalf("expected clock %s, got %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestClockWindow(t *testing.T) {
+	call, ret := event{time: fuzzBase}, event{time: fuzzBase.Add(100 * time.Millisecond)}
+	base := fuzzBase.UnixMilli()
+
+	w := (*Clock)(nil).window(call, ret)
+	if w.earliest != math.MinInt64 || w.latest != math.MaxInt64 {
+		t.Fatalf("expected an unbounded window without a clock, got %+v", w)
+	}
+
+	clock := &Clock{Lower: time.Second, Upper: 2 * time.Second}
+	w = clock.window(call, ret)
+	if w.earliest != base+1000 || w.latest != base+2100 {
+		t.Fatalf("expected window [%d, %d], got %+v", base+1000, base+2100, w)
+	}
+	if !w.passed(base+1000) || w.passed(base+1001) {
+		t.Fatalf("expected timeouts up to the earliest time to have passed, window %+v", w)
+	}
+	if !w.mayHavePassed(base+2100) || w.mayHavePassed(base+2101) {
+		t.Fatalf("expected timeouts up to the latest time to maybe have passed, window %+v", w)
+	}
+	if !w.contains(base+1000) || !w.contains(base+2100) || w.contains(base+999) || w.contains(base+2101) {
+		t.Fatalf("expected window to contain exactly [%d, %d], got %+v", base+1000, base+2100, w)
+	}
+
+	controlled := event{time: fuzzBase, serverTime: fuzzBase.Add(time.Hour)}
+	w = clock.window(controlled, ret)
+	if at := fuzzBase.Add(time.Hour).UnixMilli(); w.earliest != at || w.latest != at {
+		t.Fatalf("expected the controlled time %d, got %+v", at, w)
+	}
+}
+
+// clockDate is a read whose response carries a Date header.
+func clockDate(call, ret, date int64) store.Operation {
+	return store.Operation{
+		API:         store.Get,
+		Status:      store.Ok,
+		Code:        http.StatusOK,
+		CallEvent:   fuzzBase.Add(time.Duration(call) * time.Millisecond),
+		ReturnEvent: fuzzBase.Add(time.Duration(ret) * time.Millisecond),
+		ServerDate:  fuzzBase.Add(time.Duration(date) * time.Millisecond).Truncate(time.Second),
+	}
+}
+
+// clockCreate is a create whose promise carries a createdOn, without a Date
+// header.
+func clockCreate(call, ret, createdOn int64) store.Operation {
+	return store.Operation{
+		API:         store.Create,
+		Status:      store.Ok,
+		Code:        http.StatusCreated,
+		Output:      &openapi.Promise{Id: "p", CreatedOn: utils.ToPointer(int(fuzzBase.UnixMilli() + createdOn))},
+		CallEvent:   fuzzBase.Add(time.Duration(call) * time.Millisecond),
+		ReturnEvent: fuzzBase.Add(time.Duration(ret) * time.Millisecond),
+	}
+}
@@ -0,0 +1,345 @@
+package checker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Tolerances bound how much worse run b may be than run a before a
+// difference is flagged as a regression.
+type Tolerances struct {
+	// Latency is the allowed relative increase of any latency metric, 0.1 is 10%.
+	Latency float64
+	// Throughput is the allowed relative decrease in requests per second.
+	Throughput float64
+	// StatusCodes is the allowed decrease in the share of any 2xx status code, or increase
+	// in the share of any other code, 0.05 is 5 points.
+	StatusCodes float64
+}
+
+// LoadResult reads the result of a previous run from its results directory.
+// Runs that predate result.json fall back to performance.json and are assumed to have passed.
+func LoadResult(dir string) (*Result, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "result.json"))
+	if err == nil {
+		var result Result
+		if err := json.Unmarshal(b, &result); err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", filepath.Join(dir, "result.json"), err)
+		}
+		return &result, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	b, err = os.ReadFile(filepath.Join(dir, "performance.json"))
+	if err != nil {
+		return nil, fmt.Errorf("no run report found in '%s': %v", dir, err)
+	}
+	var perf Performance
+	if err := json.Unmarshal(b, &perf); err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", filepath.Join(dir, "performance.json"), err)
+	}
+	return &Result{Performance: &perf, Linearizable: true, ThresholdsPass: true}, nil
+}
+
+// Delta is the difference of a single metric between two runs.
+type Delta struct {
+	Section    string
+	API        string
+	Metric     string
+	A          string
+	B          string
+	Change     string
+	Regression bool
+}
+
+// Comparison holds the side by side deltas of two runs.
+type Comparison struct {
+	A      string
+	B      string
+	Deltas []Delta
+}
+
+// Regressions returns the deltas that exceed their tolerance.
+func (c *Comparison) Regressions() []Delta {
+	regressions := []Delta{}
+	for _, d := range c.Deltas {
+		if d.Regression {
+			regressions = append(regressions, d)
+		}
+	}
+	return regressions
+}
+
+// Compare computes the deltas of run b relative to run a.
+func Compare(nameA string, a *Result, nameB string, b *Result, tol Tolerances) *Comparison {
+	c := &Comparison{A: nameA, B: nameB}
+
+	// verdict, checks only one of the runs ran are shown but not compared
+	c.Deltas = append(c.Deltas, checkDelta("Linearizability",
+		a.uses(Porcupine), a.Linearizable, b.uses(Porcupine), b.Linearizable))
+	c.Deltas = append(c.Deltas, checkDelta("Dependency Graph",
+		a.Graph != nil, a.Graph != nil && a.Graph.Pass, b.Graph != nil, b.Graph != nil && b.Graph.Pass))
+	for _, m := range ConsistencyModels {
+		ca, okA := consistencyResult(a, m)
+		cb, okB := consistencyResult(b, m)
+		if okA || okB {
+			c.Deltas = append(c.Deltas, checkDelta(m.String(), okA, ca.Pass, okB, cb.Pass))
+		}
+	}
+	for _, n := range roundNumbers(a, b) {
+		ra, okA := roundResult(a, n)
+		rb, okB := roundResult(b, n)
+		c.Deltas = append(c.Deltas, checkDelta(fmt.Sprintf("Round %d", n), okA, ra.Pass, okB, rb.Pass))
+	}
+	c.Deltas = append(c.Deltas, verdictDelta("Performance Thresholds", a.ThresholdsPass, b.ThresholdsPass))
+
+	metricsA := metricsByAPI(a.Performance)
+	metricsB := metricsByAPI(b.Performance)
+	apis := unionKeys(metricsA, metricsB)
+
+	// latency
+	for _, api := range apis {
+		ma, okA := metricsA[api]
+		mb, okB := metricsB[api]
+		if !okA || !okB {
+			continue
+		}
+		latencies := []struct {
+			name string
+			a, b Duration
+		}{
+			{"mean", ma.Mean, mb.Mean},
+			{"max", ma.Max, mb.Max},
+		}
+		for _, pc := range percentiles {
+			latencies = append(latencies, struct {
+				name string
+				a, b Duration
+			}{pc.name, ma.Percentiles[pc.name], mb.Percentiles[pc.name]})
+		}
+		for _, l := range latencies {
+			change := relativeChange(float64(l.a), float64(l.b))
+			c.Deltas = append(c.Deltas, Delta{
+				Section:    "Latency",
+				API:        api,
+				Metric:     l.name,
+				A:          fmtDuration(l.a),
+				B:          fmtDuration(l.b),
+				Change:     fmtPercent(change),
+				Regression: change > tol.Latency,
+			})
+		}
+	}
+
+	// throughput
+	for _, api := range apis {
+		ma, okA := metricsA[api]
+		mb, okB := metricsB[api]
+		if !okA || !okB {
+			continue
+		}
+		change := relativeChange(ma.RPS, mb.RPS)
+		c.Deltas = append(c.Deltas, Delta{
+			Section:    "Throughput",
+			API:        api,
+			Metric:     "rps",
+			A:          fmt.Sprintf("%.2f", ma.RPS),
+			B:          fmt.Sprintf("%.2f", mb.RPS),
+			Change:     fmtPercent(change),
+			Regression: -change > tol.Throughput,
+		})
+	}
+
+	// status code mix
+	for _, api := range apis {
+		ma, mb := metricsA[api], metricsB[api]
+		codes := map[int]bool{}
+		for code := range ma.StatusCodes {
+			codes[code] = true
+		}
+		for code := range mb.StatusCodes {
+			codes[code] = true
+		}
+		sorted := make([]int, 0, len(codes))
+		for code := range codes {
+			sorted = append(sorted, code)
+		}
+		sort.Ints(sorted)
+
+		for _, code := range sorted {
+			shareA := rate(ma.StatusCodes[code], ma.Count)
+			shareB := rate(mb.StatusCodes[code], mb.Count)
+			change := shareB - shareA
+			c.Deltas = append(c.Deltas, Delta{
+				Section:    "Status Codes",
+				API:        api,
+				Metric:     fmt.Sprint(code),
+				A:          fmt.Sprintf("%.2f%%", shareA*100),
+				B:          fmt.Sprintf("%.2f%%", shareB*100),
+				Change:     fmt.Sprintf("%+.2fpp", (shareB-shareA)*100),
+				Regression: worse(code, change) > tol.StatusCodes,
+			})
+		}
+	}
+
+	return c
+}
+
+// worse returns how much worse a change in the share of a status code is,
+// successes are worse when they shrink and any other code when it grows.
+func worse(code int, change float64) float64 {
+	if code >= 200 && code < 300 {
+		return -change
+	}
+	return change
+}
+
+// String renders the comparison as a side by side table.
+func (c *Comparison) String() string {
+	build := strings.Builder{}
+	build.WriteString(fmt.Sprintf("Comparison\n=====================\nA: %s\nB: %s\n", c.A, c.B))
+
+	section := ""
+	var w *tabwriter.Writer
+	for _, d := range c.Deltas {
+		if d.Section != section {
+			if w != nil {
+				w.Flush()
+			}
+			section = d.Section
+			build.WriteString(fmt.Sprintf("\n%s:\n", section))
+			w = tabwriter.NewWriter(&build, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "  API\tMetric\tA\tB\tChange\t")
+		}
+		flag := ""
+		if d.Regression {
+			flag = "REGRESSION"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\t%s\n", d.API, d.Metric, d.A, d.B, d.Change, flag)
+	}
+	if w != nil {
+		w.Flush()
+	}
+
+	regressions := c.Regressions()
+	build.WriteString(fmt.Sprintf("\nRegressions: %d\n", len(regressions)))
+	for _, d := range regressions {
+		build.WriteString(fmt.Sprintf("  %s %s %s: %s -> %s (%s)\n", d.Section, d.API, d.Metric, d.A, d.B, d.Change))
+	}
+
+	return build.String()
+}
+
+func verdictDelta(name string, a, b bool) Delta {
+	change := "unchanged"
+	if a != b {
+		change = "changed"
+	}
+	return Delta{
+		Section:    "Verdict",
+		API:        "ALL",
+		Metric:     name,
+		A:          verdict(a),
+		B:          verdict(b),
+		Change:     change,
+		Regression: a && !b,
+	}
+}
+
+// checkDelta compares the verdict of a check, which is only compared when
+// both runs ran it.
+func checkDelta(name string, ranA, passA, ranB, passB bool) Delta {
+	if ranA && ranB {
+		return verdictDelta(name, passA, passB)
+	}
+	d := Delta{Section: "Verdict", API: "ALL", Metric: name, A: "SKIPPED", B: "SKIPPED", Change: "not compared"}
+	if ranA {
+		d.A = verdict(passA)
+	}
+	if ranB {
+		d.B = verdict(passB)
+	}
+	return d
+}
+
+func consistencyResult(r *Result, model ConsistencyModel) (ConsistencyResult, bool) {
+	for _, c := range r.Consistency {
+		if c.Model == model {
+			return c, true
+		}
+	}
+	return ConsistencyResult{}, false
+}
+
+func roundResult(r *Result, round int) (RoundResult, bool) {
+	for _, rd := range r.Rounds {
+		if rd.Round == round {
+			return rd, true
+		}
+	}
+	return RoundResult{}, false
+}
+
+// roundNumbers returns the rounds of either run in order.
+func roundNumbers(a, b *Result) []int {
+	seen := map[int]bool{}
+	numbers := []int{}
+	for _, r := range append(append([]RoundResult{}, a.Rounds...), b.Rounds...) {
+		if !seen[r.Round] {
+			seen[r.Round] = true
+			numbers = append(numbers, r.Round)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+func metricsByAPI(perf *Performance) map[string]Metrics {
+	m := map[string]Metrics{}
+	if perf == nil {
+		return m
+	}
+	for _, api := range perf.APIs {
+		m[api.API] = api
+	}
+	m[perf.Overall.API] = perf.Overall
+	return m
+}
+
+// unionKeys returns the apis of both runs, ordered with the overall metrics last.
+func unionKeys(a, b map[string]Metrics) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	for _, m := range []map[string]Metrics{a, b} {
+		for k := range m {
+			if !seen[k] && k != "ALL" && k != "" {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return append(keys, "ALL")
+}
+
+func relativeChange(a, b float64) float64 {
+	if a == 0 {
+		if b == 0 {
+			return 0
+		}
+		return 1
+	}
+	return (b - a) / a
+}
+
+func fmtPercent(v float64) string {
+	return fmt.Sprintf("%+.2f%%", v*100)
+}
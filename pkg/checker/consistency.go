@@ -0,0 +1,327 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
+)
+
+// ConsistencyModel is a guarantee a server can be checked against.
+type ConsistencyModel string
+
+const (
+	Linearizable    ConsistencyModel = "linearizable"
+	Sequential      ConsistencyModel = "sequential"
+	ReadYourWrites  ConsistencyModel = "read-your-writes"
+	MonotonicReads  ConsistencyModel = "monotonic-reads"
+	MonotonicWrites ConsistencyModel = "monotonic-writes"
+)
+
+// ConsistencyModels lists every model from strongest to weakest.
+var ConsistencyModels = []ConsistencyModel{Linearizable, Sequential, ReadYourWrites, MonotonicReads, MonotonicWrites}
+
+func (m ConsistencyModel) String() string {
+	switch m {
+	case Linearizable:
+		return "Linearizability"
+	case Sequential:
+		return "Sequential Consistency"
+	case ReadYourWrites:
+		return "Read Your Writes"
+	case MonotonicReads:
+		return "Monotonic Reads"
+	case MonotonicWrites:
+		return "Monotonic Writes"
+	default:
+		return string(m)
+	}
+}
+
+// ParseConsistencyModels parses model names, "all" selects every model.
+func ParseConsistencyModels(names []string) ([]ConsistencyModel, error) {
+	models := []ConsistencyModel{}
+	seen := map[ConsistencyModel]bool{}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "all" {
+			return ConsistencyModels, nil
+		}
+
+		var found bool
+		for _, m := range ConsistencyModels {
+			if string(m) == name {
+				found = true
+				if !seen[m] {
+					seen[m] = true
+					models = append(models, m)
+				}
+				break
+			}
+		}
+		if !found {
+			valid := make([]string, len(ConsistencyModels))
+			for i, m := range ConsistencyModels {
+				valid[i] = string(m)
+			}
+			return nil, fmt.Errorf("unknown consistency model '%s', expected one of: all, %s", name, strings.Join(valid, ", "))
+		}
+	}
+	return models, nil
+}
+
+// ConsistencyResult is the outcome of checking a history against a model
+// weaker than linearizability.
+type ConsistencyResult struct {
+	Model ConsistencyModel `json:"model"`
+	Pass  bool             `json:"pass"`
+	// Inconclusive is set when the check ran out of time, the result is then
+	// treated as a pass just like an unknown linearizability result.
+	Inconclusive bool        `json:"inconclusive,omitempty"`
+	Violations   []Violation `json:"violations,omitempty"`
+}
+
+// Violation points to an operation that broke a guarantee.
+type Violation struct {
+	OperationID int    `json:"operationId"`
+	ClientID    int    `json:"clientId"`
+	API         string `json:"api"`
+	Description string `json:"description"`
+	TraceID     string `json:"traceId,omitempty"`
+	Reason      string `json:"reason"`
+}
+
+func newViolation(op store.Operation, reason string) Violation {
+	return Violation{
+		OperationID: op.ID,
+		ClientID:    op.ClientID,
+		API:         op.API.String(),
+		Description: describe(event{API: op.API, value: op.Input, fuzz: op.Fuzz}),
+		TraceID:     op.TraceID,
+		Reason:      reason,
+	}
+}
+
+// maxViolations caps the number of violations listed in the summary.
+const maxViolations = 10
+
+func (r ConsistencyResult) String() string {
+	build := strings.Builder{}
+	v := verdict(r.Pass)
+	if r.Inconclusive {
+		v = "UNKNOWN (timed out)"
+	}
+	build.WriteString(fmt.Sprintf("%s Check: %s\n", r.Model.String(), v))
+	for i, violation := range r.Violations {
+		if i == maxViolations {
+			build.WriteString(fmt.Sprintf("  ... and %d more\n", len(r.Violations)-maxViolations))
+			break
+		}
+		build.WriteString(fmt.Sprintf("  %s (id=%d, clientId=%d): %s\n", violation.Description, violation.OperationID, violation.ClientID, violation.Reason))
+	}
+	return build.String()
+}
+
+// checkConsistency checks the history against a model other than linearizability.
+func checkConsistency(model ConsistencyModel, history []store.Operation, config modelConfig, timeout time.Duration) ConsistencyResult {
+	switch model {
+	case Sequential:
+		return checkSequential(history, config, timeout)
+	default:
+		return checkSession(model, history)
+	}
+}
+
+//
+// sequential consistency
+//
+
+// sequentialSearch looks for an interleaving of the client histories that is
+// legal with respect to the model. Unlike linearizability, operations of
+// different clients may be reordered freely, only the order of operations
+// within a client is kept.
+type sequentialSearch struct {
+	model    *DurablePromiseModel
+	clients  [][]opEvents
+	seen     map[string]bool
+	deadline time.Time
+	timedOut bool
+
+	// deepest is the furthest point the search got to, used to explain failures.
+	deepest      []int
+	deepestState State
+	deepestSteps int
+}
+
+type opEvents struct {
+	op          store.Operation
+	call, ret   event
+	stateChange bool
+}
+
+func checkSequential(history []store.Operation, config modelConfig, timeout time.Duration) ConsistencyResult {
+	s := &sequentialSearch{
+		model:        newDurablePromiseModel(config),
+		seen:         map[string]bool{},
+		deadline:     time.Now().Add(timeout),
+		deepestSteps: -1,
+	}
+
+	index := map[int]int{}
+	for _, op := range clientOrder(history) {
+		if _, ok := index[op.ClientID]; !ok {
+			index[op.ClientID] = len(s.clients)
+			s.clients = append(s.clients, nil)
+		}
+		evs := makeEvents([]store.Operation{op})
+		i := index[op.ClientID]
+		s.clients[i] = append(s.clients[i], opEvents{
+			op:          op,
+			call:        evs[0],
+			ret:         evs[1],
+			stateChange: op.Status == store.Ok && (op.API != store.Get && op.API != store.Search || observesTimeout(op)),
+		})
+	}
+
+	result := ConsistencyResult{Model: Sequential}
+	pos := make([]int, len(s.clients))
+	if s.search(s.model.Init(), pos, 0) {
+		result.Pass = true
+		return result
+	}
+	if s.timedOut {
+		result.Pass, result.Inconclusive = true, true
+		return result
+	}
+
+	// every client is blocked at the deepest point of the search
+	for c, ops := range s.clients {
+		if s.deepest[c] >= len(ops) {
+			continue
+		}
+		next := ops[s.deepest[c]]
+		reason := "operation can not be ordered after the longest legal interleaving"
+		if _, err := s.model.Step(copyState(s.deepestState), next.call, next.ret); err != nil {
+			reason = err.Error()
+		}
+		result.Violations = append(result.Violations, newViolation(next.op, fmt.Sprintf("no legal interleaving after %d of %d operations: %s", s.deepestSteps, len(history), reason)))
+	}
+	return result
+}
+
+// observesTimeout reports whether a read found a timed out promise, which
+// moves the model from pending to timed out like a write.
+func observesTimeout(op store.Operation) bool {
+	switch out := op.Output.(type) {
+	case *openapi.Promise:
+		return out != nil && out.State == openapi.PromiseStateREJECTEDTIMEDOUT
+	case *openapi.SearchPromisesResponseObj:
+		for _, p := range utils.SafeDereference(out.Promises) {
+			if p.State == openapi.PromiseStateREJECTEDTIMEDOUT {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *sequentialSearch) search(state State, pos []int, steps int) bool {
+	if time.Now().After(s.deadline) {
+		s.timedOut = true
+		return false
+	}
+
+	// operations that do not change the state can be taken as soon as they are
+	// legal, any interleaving that takes them later can be reordered to take
+	// them now
+	pos = append([]int{}, pos...)
+	for progress := true; progress; {
+		progress = false
+		for c, ops := range s.clients {
+			if pos[c] >= len(ops) || ops[pos[c]].stateChange {
+				continue
+			}
+			next := ops[pos[c]]
+			if newState, err := s.model.Step(copyState(state), next.call, next.ret); err == nil {
+				state = newState
+				pos[c]++
+				steps++
+				progress = true
+			}
+		}
+	}
+
+	if steps > s.deepestSteps {
+		s.deepest, s.deepestState, s.deepestSteps = pos, state, steps
+	}
+
+	done := true
+	for c, ops := range s.clients {
+		if pos[c] < len(ops) {
+			done = false
+			break
+		}
+	}
+	if done {
+		return true
+	}
+
+	key := searchKey(state, pos)
+	if s.seen[key] {
+		return false
+	}
+	s.seen[key] = true
+
+	// try the clients in the order their next operations were called, the
+	// order they really took effect in is the most likely to be legal
+	candidates := []int{}
+	for c, ops := range s.clients {
+		if pos[c] < len(ops) && ops[pos[c]].stateChange {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return s.clients[candidates[i]][pos[candidates[i]]].op.CallEvent.Before(s.clients[candidates[j]][pos[candidates[j]]].op.CallEvent)
+	})
+
+	for _, c := range candidates {
+		next := s.clients[c][pos[c]]
+		newState, err := s.model.Step(copyState(state), next.call, next.ret)
+		if err != nil {
+			continue
+		}
+		pos[c]++
+		if s.search(newState, pos, steps+1) {
+			return true
+		}
+		pos[c]--
+		if s.timedOut {
+			return false
+		}
+	}
+
+	return false
+}
+
+// copyState protects branches of the search from verifiers that update the state in place.
+func copyState(state State) State {
+	c := make(State, len(state))
+	for k, v := range state {
+		if v != nil {
+			p := *v
+			v = &p
+		}
+		c[k] = v
+	}
+	return c
+}
+
+func searchKey(state State, pos []int) string {
+	b, _ := json.Marshal(state)
+	return fmt.Sprintf("%v%s", pos, b)
+}
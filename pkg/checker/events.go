@@ -32,6 +32,10 @@ type event struct {
 	time     time.Time
 	status   store.Status
 	code     int
+	fuzz     *store.Fuzz
+
+	// serverTime is the time of a controlled server clock.
+	serverTime time.Time
 }
 
 func (e event) String() string {
@@ -64,6 +68,9 @@ func makeEvents(history []store.Operation) []event {
 			time:     op.CallEvent,
 			status:   store.Invoke, // status is invoking
 			code:     -1,           // code is unknown
+			fuzz:     op.Fuzz,
+
+			serverTime: op.ServerTime,
 		})
 
 		// response
@@ -76,6 +83,9 @@ func makeEvents(history []store.Operation) []event {
 			time:     op.ReturnEvent,
 			status:   op.Status,
 			code:     op.Code,
+			fuzz:     op.Fuzz,
+
+			serverTime: op.ServerTime,
 		})
 	}
 
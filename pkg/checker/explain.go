@@ -0,0 +1,134 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anishathalye/porcupine"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+// Explanation points to the operation that could not be linearized.
+type Explanation struct {
+	// Round is the round of the operation in a run with several rounds.
+	Round int `json:"round,omitempty"`
+	// Linearized is the length of the longest legal prefix found by the checker.
+	Linearized  int    `json:"linearized"`
+	Total       int    `json:"total"`
+	OperationID int    `json:"operationId"`
+	ClientID    int    `json:"clientId"`
+	API         string `json:"api"`
+	Description string `json:"description"`
+	TraceID     string `json:"traceId,omitempty"`
+	Reason      string `json:"reason"`
+	State       string `json:"state"`
+	// Observed are the writes whose values the operation observed, as far
+	// as values can be traced back to writes.
+	Observed []OperationRef `json:"observed,omitempty"`
+}
+
+func (e *Explanation) String() string {
+	build := strings.Builder{}
+	build.WriteString("Failure Explanation:\n")
+	if e.Round > 0 {
+		build.WriteString(fmt.Sprintf("  Round: %d\n", e.Round))
+	}
+	build.WriteString(fmt.Sprintf("  Linearized: %d of %d operations\n", e.Linearized, e.Total))
+	build.WriteString(fmt.Sprintf("  Operation: %s (id=%d, clientId=%d)\n", e.Description, e.OperationID, e.ClientID))
+	if e.TraceID != "" {
+		build.WriteString(fmt.Sprintf("  Trace: %s\n", e.TraceID))
+	}
+	build.WriteString(fmt.Sprintf("  Reason: %s\n", e.Reason))
+	for _, w := range e.Observed {
+		build.WriteString(fmt.Sprintf("  Observed: value written by %s\n", w))
+	}
+	for _, line := range strings.Split(strings.TrimSpace(e.State), "\n") {
+		build.WriteString(fmt.Sprintf("  %s\n", line))
+	}
+	return build.String()
+}
+
+// explain finds the operation that could not be appended to the longest
+// partial linearization porcupine found and replays the model to describe why.
+func explain(info porcupine.LinearizationInfo, events []porcupine.Event, history []store.Operation, config modelConfig) *Explanation {
+	var longest []int
+	for _, partition := range info.PartialLinearizations() {
+		for _, partial := range partition {
+			if longest == nil || len(partial) > len(longest) {
+				longest = partial
+			}
+		}
+	}
+
+	// porcupine renumbers operations in order of first appearance
+	calls, returns := []event{}, []event{}
+	index := map[int]int{}
+	for _, e := range events {
+		ev := e.Value.(event)
+		if _, ok := index[e.Id]; !ok {
+			index[e.Id] = len(calls)
+			calls = append(calls, event{})
+			returns = append(returns, event{})
+		}
+		if ev.kind == callEvent {
+			calls[index[e.Id]] = ev
+		} else {
+			returns[index[e.Id]] = ev
+		}
+	}
+
+	linearized := map[int]bool{}
+	for _, i := range longest {
+		linearized[i] = true
+	}
+
+	// the earliest returning operation outside of the prefix must come next
+	next := -1
+	for i := range returns {
+		if linearized[i] {
+			continue
+		}
+		if next == -1 || returns[i].time.Before(returns[next].time) {
+			next = i
+		}
+	}
+	if next == -1 {
+		return nil
+	}
+
+	model := newDurablePromiseModel(config)
+	state := model.Init()
+	for _, i := range longest {
+		state, _ = model.Step(state, calls[i], returns[i])
+	}
+
+	reason := "operation can not be linearized after the longest legal prefix"
+	if _, err := model.Step(state, calls[next], returns[next]); err != nil {
+		reason = err.Error()
+	}
+
+	e := &Explanation{
+		Linearized:  len(longest),
+		Total:       len(calls),
+		OperationID: calls[next].id,
+		ClientID:    calls[next].clientId,
+		API:         calls[next].API.String(),
+		Description: describe(calls[next]),
+		Reason:      reason,
+		State:       state.String(),
+	}
+	writes := newWriteIndex(history)
+	for i := range history {
+		if history[i].ID == e.OperationID {
+			e.TraceID = history[i].TraceID
+			for _, w := range writes.observed(history[i]) {
+				if w != i {
+					e.Observed = append(e.Observed, newOperationRef(history[w]))
+				}
+			}
+			break
+		}
+	}
+
+	return e
+}
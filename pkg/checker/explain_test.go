@@ -0,0 +1,30 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/anishathalye/porcupine"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+// TestExplain checks the explanation points to the operation that follows
+// the longest partial linearization porcupine found.
+func TestExplain(t *testing.T) {
+	// the read sees a param no create wrote
+	history := []store.Operation{
+		graphCreate(1, 0, 0, 10),
+		graphGet(2, 1, 20, 30, graphPromise(3, 0, openapi.PromiseStatePENDING)),
+	}
+
+	model, events := newPorcupineModel(modelConfig{}), makePorcupineEvents(history)
+	out, info := porcupine.CheckEventsVerbose(model, events, 0)
+	if out != porcupine.Illegal {
+		t.Fatalf("expected the history to be illegal, got %v\n%s", out, dumpHistory(history))
+	}
+
+	e := explain(info, events, history, modelConfig{})
+	if e == nil || e.Linearized != 1 || e.OperationID != 2 {
+		t.Fatalf("expected the read to follow a prefix of 1 operation, got %+v", e)
+	}
+}
@@ -0,0 +1,715 @@
+package checker
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
+)
+
+// The graph checker infers dependencies between operations from the values
+// they read and write, in the spirit of elle. A value can only be traced back
+// to the write that produced it when it encodes the operation that wrote it,
+// or when no other write carries the same value. Values shared by several
+// writes are skipped rather than guessed.
+
+// AnomalyKind names a class of anomaly found in the dependency graph.
+type AnomalyKind string
+
+const (
+	LostUpdate          AnomalyKind = "lost-update"
+	StaleRead           AnomalyKind = "stale-read"
+	DuplicateCompletion AnomalyKind = "duplicate-completion"
+	Cycle               AnomalyKind = "cycle"
+)
+
+// edge kinds of the dependency graph
+const (
+	wwEdge = "ww" // a completion applied on top of a create
+	wrEdge = "wr" // a read observed a write
+	rwEdge = "rw" // a read observed a version a write later replaced
+	poEdge = "po" // process order of a client
+	rtEdge = "rt" // real time order
+)
+
+// OperationRef identifies an operation of the history.
+type OperationRef struct {
+	OperationID int    `json:"operationId"`
+	ClientID    int    `json:"clientId"`
+	API         string `json:"api"`
+	Description string `json:"description"`
+	TraceID     string `json:"traceId,omitempty"`
+}
+
+func newOperationRef(op store.Operation) OperationRef {
+	return OperationRef{
+		OperationID: op.ID,
+		ClientID:    op.ClientID,
+		API:         op.API.String(),
+		Description: describe(event{API: op.API, value: op.Input, fuzz: op.Fuzz}),
+		TraceID:     op.TraceID,
+	}
+}
+
+func (r OperationRef) String() string {
+	return fmt.Sprintf("%s (id=%d, clientId=%d)", r.Description, r.OperationID, r.ClientID)
+}
+
+// Anomaly is a named violation found in the dependency graph.
+type Anomaly struct {
+	Kind       AnomalyKind    `json:"kind"`
+	Promise    string         `json:"promise,omitempty"`
+	Operations []OperationRef `json:"operations"`
+	// Edges holds the kind of every edge of a cycle, edge i leads from
+	// operation i to operation i+1 and the last edge closes the cycle.
+	Edges       []string `json:"edges,omitempty"`
+	Description string   `json:"description"`
+}
+
+func (a Anomaly) String() string {
+	build := strings.Builder{}
+	build.WriteString(fmt.Sprintf("%s: %s\n", a.Kind, a.Description))
+	for i, op := range a.Operations {
+		if i < len(a.Edges) {
+			build.WriteString(fmt.Sprintf("    %s -%s->\n", op, a.Edges[i]))
+		} else {
+			build.WriteString(fmt.Sprintf("    %s\n", op))
+		}
+	}
+	return build.String()
+}
+
+// GraphResult is the outcome of the dependency graph checker.
+type GraphResult struct {
+	Pass       bool      `json:"pass"`
+	Operations int       `json:"operations"`
+	Edges      int       `json:"edges"`
+	Anomalies  []Anomaly `json:"anomalies,omitempty"`
+}
+
+func (r *GraphResult) String() string {
+	build := strings.Builder{}
+	build.WriteString(fmt.Sprintf("Dependency Graph Check: %s (%d operations, %d edges)\n", verdict(r.Pass), r.Operations, r.Edges))
+	for i, a := range r.Anomalies {
+		if i == maxViolations {
+			build.WriteString(fmt.Sprintf("  ... and %d more\n", len(r.Anomalies)-maxViolations))
+			break
+		}
+		build.WriteString("  " + a.String())
+	}
+	return build.String()
+}
+
+// dependencyGraph is a graph over the indices of the operations of a history.
+type dependencyGraph struct {
+	ops   []store.Operation
+	edges []map[int]string
+	count int
+}
+
+func newDependencyGraph(ops []store.Operation) *dependencyGraph {
+	g := &dependencyGraph{ops: ops, edges: make([]map[int]string, len(ops))}
+	for i := range g.edges {
+		g.edges[i] = map[int]string{}
+	}
+	return g
+}
+
+// link adds an edge, dependencies take precedence over ordering edges when labelling.
+func (g *dependencyGraph) link(from, to int, kind string) {
+	if from == to {
+		return
+	}
+	existing, ok := g.edges[from][to]
+	if !ok {
+		g.count++
+	}
+	if !ok || existing == poEdge || existing == rtEdge {
+		g.edges[from][to] = kind
+	}
+}
+
+// neighbours returns the targets of the edges of a node in a stable order.
+func (g *dependencyGraph) neighbours(n int) []int {
+	to := make([]int, 0, len(g.edges[n]))
+	for t := range g.edges[n] {
+		to = append(to, t)
+	}
+	sort.Ints(to)
+	return to
+}
+
+// promiseRead is a single promise observed by an operation.
+type promiseRead struct {
+	id      string
+	promise *openapi.Promise // nil when the promise was observed to be absent
+}
+
+// observations returns every promise an operation observed, including what a
+// write responded with.
+func observations(op store.Operation) []promiseRead {
+	if op.Status != store.Ok {
+		if op.Status == store.Fail && op.Code == http.StatusNotFound {
+			if id, ok := targets(op); ok {
+				return []promiseRead{{id: id}}
+			}
+			if id, ok := op.Input.(string); ok {
+				return []promiseRead{{id: id}}
+			}
+		}
+		return nil
+	}
+
+	switch v := op.Output.(type) {
+	case *openapi.Promise:
+		if v != nil {
+			return []promiseRead{{id: v.Id, promise: v}}
+		}
+	case *openapi.SearchPromisesResponseObj:
+		if v != nil && v.Promises != nil {
+			obs := make([]promiseRead, len(*v.Promises))
+			for i := range *v.Promises {
+				obs[i] = promiseRead{id: (*v.Promises)[i].Id, promise: &(*v.Promises)[i]}
+			}
+			return obs
+		}
+	}
+	return nil
+}
+
+func createKey(id string, param *openapi.PromiseValue) string {
+	var data string
+	if param != nil {
+		data = utils.SafeDereference(param.Data)
+	}
+	return id + "\x00" + data
+}
+
+func completeKey(id string, state string, value *openapi.PromiseValue) string {
+	var data string
+	if value != nil {
+		data = utils.SafeDereference(value.Data)
+	}
+	return id + "\x00" + state + "\x00" + data
+}
+
+// writeIndex traces values back to the writes that may have produced them.
+type writeIndex struct {
+	ops       []store.Operation
+	byID      map[int]int
+	creates   map[string][]int
+	completes map[string][]int
+}
+
+// mayHaveApplied reports whether a write could have taken effect, writes
+// without a response or with a server error are indeterminate.
+func mayHaveApplied(op store.Operation) bool {
+	return op.Status != store.Fail || op.Code >= 500
+}
+
+func newWriteIndex(ops []store.Operation) *writeIndex {
+	w := &writeIndex{ops: ops, byID: map[int]int{}, creates: map[string][]int{}, completes: map[string][]int{}}
+	for i, op := range ops {
+		w.byID[op.ID] = i
+		if !mayHaveApplied(op) {
+			continue
+		}
+		switch v := op.Input.(type) {
+		case *openapi.CreatePromiseJSONRequestBody:
+			if v != nil {
+				key := createKey(v.Id, v.Param)
+				w.creates[key] = append(w.creates[key], i)
+			}
+		case *openapi.CompletePromiseRequestWrapper:
+			if v == nil || v.Id == nil {
+				continue
+			}
+			if body, ok := v.Request.(*openapi.PatchPromisesIdJSONRequestBody); ok && body != nil {
+				key := completeKey(*v.Id, string(body.State), body.Value)
+				w.completes[key] = append(w.completes[key], i)
+			}
+		}
+	}
+	return w
+}
+
+func unique(writes []int) (int, bool) {
+	if len(writes) != 1 {
+		return -1, false
+	}
+	return writes[0], true
+}
+
+// decode returns the write a value encodes, if it is a write of the promise
+// with one of the given apis.
+func (w *writeIndex) decode(id string, value openapi.PromiseValue, apis ...store.API) (int, bool) {
+	data, err := base64.StdEncoding.DecodeString(utils.SafeDereference(value.Data))
+	if err != nil {
+		return -1, false
+	}
+	ref, ok := store.DecodeValue(data)
+	if !ok {
+		return -1, false
+	}
+	i, ok := w.byID[ref.OperationID]
+	if !ok {
+		return -1, false
+	}
+	if target, ok := targets(w.ops[i]); !ok || target != id {
+		return -1, false
+	}
+	for _, api := range apis {
+		if w.ops[i].API == api {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// creator returns the create that produced the observed promise.
+func (w *writeIndex) creator(p *openapi.Promise) (int, bool) {
+	if i, ok := w.decode(p.Id, p.Param, store.Create); ok {
+		return i, true
+	}
+	return unique(w.creates[createKey(p.Id, &p.Param)])
+}
+
+// observed returns the writes whose values an operation observed.
+func (w *writeIndex) observed(op store.Operation) []int {
+	writes := []int{}
+	for _, o := range observations(op) {
+		if o.promise == nil {
+			continue
+		}
+		if c, ok := w.creator(o.promise); ok {
+			writes = append(writes, c)
+		}
+		if k, ok := w.completer(o.promise); ok {
+			writes = append(writes, k)
+		}
+	}
+	return writes
+}
+
+// completer returns the completion that produced the observed promise.
+func (w *writeIndex) completer(p *openapi.Promise) (int, bool) {
+	if p.State == openapi.PromiseStatePENDING || p.State == openapi.PromiseStateREJECTEDTIMEDOUT {
+		return -1, false
+	}
+	if i, ok := w.decode(p.Id, p.Value, store.Cancel, store.Resolve, store.Reject); ok {
+		return i, true
+	}
+	return unique(w.completes[completeKey(p.Id, string(p.State), &p.Value)])
+}
+
+// checkGraph builds the dependency graph of a history and reports the anomalies found in it.
+func checkGraph(history []store.Operation) *GraphResult {
+	ops := history
+	g := newDependencyGraph(ops)
+	writes := newWriteIndex(ops)
+	anomalies := []Anomaly{}
+
+	// writes known to have taken effect because they were observed
+	appliedCreates, appliedCompletes := map[string][]int{}, map[string][]int{}
+	seenCreate, seenComplete := map[int]bool{}, map[int]bool{}
+	for i := range ops {
+		for _, o := range observations(ops[i]) {
+			if o.promise == nil {
+				continue
+			}
+			if w, ok := writes.creator(o.promise); ok && !seenCreate[w] {
+				seenCreate[w] = true
+				appliedCreates[o.id] = append(appliedCreates[o.id], w)
+			}
+			if w, ok := writes.completer(o.promise); ok && !seenComplete[w] {
+				seenComplete[w] = true
+				appliedCompletes[o.id] = append(appliedCompletes[o.id], w)
+			}
+		}
+	}
+
+	// dependency edges
+	for i := range ops {
+		for _, o := range observations(ops[i]) {
+			if o.promise == nil {
+				for _, w := range appliedCreates[o.id] {
+					g.link(i, w, rwEdge)
+				}
+				continue
+			}
+
+			c, hasCreator := writes.creator(o.promise)
+			if hasCreator {
+				g.link(c, i, wrEdge)
+			}
+
+			if k, ok := writes.completer(o.promise); ok {
+				g.link(k, i, wrEdge)
+				if hasCreator {
+					g.link(c, k, wwEdge)
+				}
+			} else if o.promise.State == openapi.PromiseStatePENDING {
+				for _, k := range appliedCompletes[o.id] {
+					g.link(i, k, rwEdge)
+				}
+			}
+		}
+	}
+
+	linkOrder(g)
+
+	anomalies = append(anomalies, duplicateCompletions(ops, writes)...)
+	anomalies = append(anomalies, staleAndLost(ops, writes)...)
+	anomalies = append(anomalies, cycles(g)...)
+
+	return &GraphResult{
+		Pass:       len(anomalies) == 0,
+		Operations: len(ops),
+		Edges:      g.count,
+		Anomalies:  anomalies,
+	}
+}
+
+// linkOrder adds process order and real time edges. Real time edges are only
+// added from the frontier of operations that are not already implied by a
+// later operation, which keeps the graph linear in the size of the history.
+func linkOrder(g *dependencyGraph) {
+	last := map[int]int{}
+	for _, i := range byCall(g.ops) {
+		if prev, ok := last[g.ops[i].ClientID]; ok {
+			g.link(prev, i, poEdge)
+		}
+		last[g.ops[i].ClientID] = i
+	}
+
+	events := makeEvents(g.ops)
+	index := make(map[int][]int, len(g.ops))
+	for i, op := range g.ops {
+		index[op.ID] = append(index[op.ID], i)
+	}
+	calls, returns := map[int]int{}, map[int]int{}
+	resolve := func(e event, seen map[int]int) int {
+		i := index[e.id][seen[e.id]]
+		seen[e.id]++
+		return i
+	}
+
+	frontier := map[int]bool{}
+	predecessors := make([][]int, len(g.ops))
+	for _, e := range events {
+		if e.kind == callEvent {
+			i := resolve(e, calls)
+			for f := range frontier {
+				g.link(f, i, rtEdge)
+				predecessors[i] = append(predecessors[i], f)
+			}
+			continue
+		}
+		i := resolve(e, returns)
+		for _, p := range predecessors[i] {
+			delete(frontier, p)
+		}
+		frontier[i] = true
+	}
+}
+
+func byCall(ops []store.Operation) []int {
+	order := make([]int, len(ops))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return ops[order[a]].CallEvent.Before(ops[order[b]].CallEvent)
+	})
+	return order
+}
+
+// won reports whether a write was acknowledged with its own value, meaning
+// the server claims the write took effect.
+func won(op store.Operation, i int, writes *writeIndex) bool {
+	if op.Status != store.Ok {
+		return false
+	}
+	p, ok := op.Output.(*openapi.Promise)
+	if !ok || p == nil {
+		return false
+	}
+	switch op.API {
+	case store.Create:
+		w, ok := writes.creator(p)
+		return ok && w == i && op.Code == http.StatusCreated
+	case store.Cancel, store.Resolve, store.Reject:
+		w, ok := writes.completer(p)
+		return ok && w == i
+	default:
+		return false
+	}
+}
+
+// duplicateCompletions finds promises more than one completion claims to have completed.
+func duplicateCompletions(ops []store.Operation, writes *writeIndex) []Anomaly {
+	winners := map[string][]int{}
+	for i, op := range ops {
+		if op.API == store.Create || !won(op, i, writes) {
+			continue
+		}
+		id, _ := targets(op)
+		winners[id] = append(winners[id], i)
+	}
+
+	anomalies := []Anomaly{}
+	for _, id := range sortedKeys(winners) {
+		if len(winners[id]) < 2 {
+			continue
+		}
+		refs := make([]OperationRef, len(winners[id]))
+		for j, i := range winners[id] {
+			refs[j] = newOperationRef(ops[i])
+		}
+		anomalies = append(anomalies, Anomaly{
+			Kind:        DuplicateCompletion,
+			Promise:     id,
+			Operations:  refs,
+			Description: fmt.Sprintf("promise '%s' was completed %d times", id, len(refs)),
+		})
+	}
+	return anomalies
+}
+
+// staleAndLost compares every observation with the writes acknowledged before
+// the observing operation started.
+func staleAndLost(ops []store.Operation, writes *writeIndex) []Anomaly {
+	acked := map[string][]int{}
+	for i, op := range ops {
+		if won(op, i, writes) {
+			id, _ := targets(op)
+			acked[id] = append(acked[id], i)
+		}
+	}
+
+	anomalies := []Anomaly{}
+	for r, op := range ops {
+		for _, o := range observations(op) {
+			for _, w := range acked[o.id] {
+				if w == r || !ops[w].ReturnEvent.Before(op.CallEvent) {
+					continue
+				}
+				if a, ok := compare(ops, writes, w, r, o); ok {
+					anomalies = append(anomalies, a)
+				}
+			}
+		}
+	}
+	return anomalies
+}
+
+// compare checks a single observation against a write acknowledged before it.
+func compare(ops []store.Operation, writes *writeIndex, w, r int, o promiseRead) (Anomaly, bool) {
+	write, read := ops[w], ops[r]
+	refs := []OperationRef{newOperationRef(write), newOperationRef(read)}
+
+	stale := func(seen string) (Anomaly, bool) {
+		description := fmt.Sprintf("observed promise '%s' %s after %s was acknowledged", o.id, seen, refs[0])
+		if o.promise != nil {
+			if c, ok := writes.creator(o.promise); ok {
+				saw := newOperationRef(ops[c])
+				refs = append(refs, saw)
+				description = fmt.Sprintf("observed promise '%s' %s as written by %s after %s was acknowledged", o.id, seen, saw, refs[0])
+			}
+		}
+		return Anomaly{
+			Kind:        StaleRead,
+			Promise:     o.id,
+			Operations:  refs,
+			Description: description,
+		}, true
+	}
+	lost := func(other int) (Anomaly, bool) {
+		return Anomaly{
+			Kind:        LostUpdate,
+			Promise:     o.id,
+			Operations:  append(refs, newOperationRef(ops[other])),
+			Description: fmt.Sprintf("acknowledged %s was lost, promise '%s' holds the value of %s", refs[0], o.id, newOperationRef(ops[other])),
+		}, true
+	}
+
+	if o.promise == nil {
+		return stale("absent")
+	}
+
+	if write.API == store.Create {
+		if c, ok := writes.creator(o.promise); ok && c != w {
+			return lost(c)
+		}
+		return Anomaly{}, false
+	}
+
+	if o.promise.State == openapi.PromiseStatePENDING {
+		return stale("pending")
+	}
+	if o.promise.State == openapi.PromiseStateREJECTEDTIMEDOUT {
+		return Anomaly{
+			Kind:        LostUpdate,
+			Promise:     o.id,
+			Operations:  refs,
+			Description: fmt.Sprintf("acknowledged %s was lost, promise '%s' timed out", refs[0], o.id),
+		}, true
+	}
+	if k, ok := writes.completer(o.promise); ok && k != w {
+		return lost(k)
+	}
+	return Anomaly{}, false
+}
+
+// cycles finds the strongly connected components of the graph and reports a
+// cycle through each of them.
+func cycles(g *dependencyGraph) []Anomaly {
+	anomalies := []Anomaly{}
+	for _, component := range stronglyConnected(g) {
+		if len(component) < 2 {
+			continue
+		}
+		path, kinds := findCycle(g, component)
+		refs := make([]OperationRef, len(path))
+		for i, n := range path {
+			refs[i] = newOperationRef(g.ops[n])
+		}
+		anomalies = append(anomalies, Anomaly{
+			Kind:        Cycle,
+			Operations:  refs,
+			Edges:       kinds,
+			Description: fmt.Sprintf("%d operations depend on each other (%s), %d operations in the cycle", len(component), strings.Join(kinds, ", "), len(path)),
+		})
+	}
+	return anomalies
+}
+
+// stronglyConnected is an iterative version of tarjan's algorithm, histories
+// can be too large for a recursive one.
+func stronglyConnected(g *dependencyGraph) [][]int {
+	n := len(g.ops)
+	index, low := make([]int, n), make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = -1
+	}
+
+	neighbours := make([][]int, n)
+	for i := range g.edges {
+		neighbours[i] = g.neighbours(i)
+	}
+
+	type frame struct{ node, next int }
+	var stack []int
+	var components [][]int
+	counter := 0
+
+	for root := 0; root < n; root++ {
+		if index[root] != -1 {
+			continue
+		}
+		call := []frame{{node: root}}
+		index[root], low[root] = counter, counter
+		counter++
+		stack = append(stack, root)
+		onStack[root] = true
+
+		for len(call) > 0 {
+			f := &call[len(call)-1]
+			if f.next < len(neighbours[f.node]) {
+				to := neighbours[f.node][f.next]
+				f.next++
+				if index[to] == -1 {
+					index[to], low[to] = counter, counter
+					counter++
+					stack = append(stack, to)
+					onStack[to] = true
+					call = append(call, frame{node: to})
+				} else if onStack[to] && index[to] < low[f.node] {
+					low[f.node] = index[to]
+				}
+				continue
+			}
+
+			node := f.node
+			call = call[:len(call)-1]
+			if len(call) > 0 {
+				parent := call[len(call)-1].node
+				if low[node] < low[parent] {
+					low[parent] = low[node]
+				}
+			}
+			if low[node] == index[node] {
+				var component []int
+				for {
+					top := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[top] = false
+					component = append(component, top)
+					if top == node {
+						break
+					}
+				}
+				sort.Ints(component)
+				components = append(components, component)
+			}
+		}
+	}
+	return components
+}
+
+// findCycle returns the shortest cycle through the first node of a strongly
+// connected component along with the kind of each of its edges.
+func findCycle(g *dependencyGraph, component []int) ([]int, []string) {
+	in := map[int]bool{}
+	for _, n := range component {
+		in[n] = true
+	}
+
+	start := component[0]
+	parent := map[int]int{start: -1}
+	queue := []int{start}
+	end := -1
+	for len(queue) > 0 && end == -1 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, to := range g.neighbours(n) {
+			if to == start {
+				end = n
+				break
+			}
+			if _, ok := parent[to]; !ok && in[to] {
+				parent[to] = n
+				queue = append(queue, to)
+			}
+		}
+	}
+
+	path := []int{}
+	for n := end; n != -1; n = parent[n] {
+		path = append([]int{n}, path...)
+	}
+	kinds := make([]string, len(path))
+	for i, n := range path {
+		next := start
+		if i+1 < len(path) {
+			next = path[i+1]
+		}
+		kinds[i] = g.edges[n][next]
+	}
+	return path, kinds
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
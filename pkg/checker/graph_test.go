@@ -0,0 +1,158 @@
+package checker
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
+)
+
+// TestGraphAnomalies checks the graph checker names the anomaly of small
+// hand written histories of promise 'p', and accepts a correct one.
+func TestGraphAnomalies(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []store.Operation
+		want    AnomalyKind // empty when the history is correct
+	}{
+		{
+			name: "correct",
+			history: []store.Operation{
+				graphCreate(1, 0, 0, 10),
+				graphComplete(2, 0, store.Resolve, 20, 30),
+				graphGet(3, 1, 40, 50, graphPromise(1, 2, openapi.PromiseStateRESOLVED)),
+			},
+		},
+		{
+			// the read starts after the resolve was acknowledged
+			name: "stale read",
+			history: []store.Operation{
+				graphCreate(1, 0, 0, 10),
+				graphComplete(2, 0, store.Resolve, 20, 30),
+				graphGet(3, 1, 40, 50, graphPromise(1, 0, openapi.PromiseStatePENDING)),
+			},
+			want: StaleRead,
+		},
+		{
+			// both creates are acknowledged as new, the read holds the first
+			name: "lost update",
+			history: []store.Operation{
+				graphCreate(1, 0, 0, 10),
+				graphCreate(2, 1, 20, 30),
+				graphGet(3, 1, 40, 50, graphPromise(1, 0, openapi.PromiseStatePENDING)),
+			},
+			want: LostUpdate,
+		},
+		{
+			// the resolve was acknowledged, the promise can not time out
+			name: "lost completion",
+			history: []store.Operation{
+				graphCreate(1, 0, 0, 10),
+				graphComplete(2, 0, store.Resolve, 20, 30),
+				graphGet(3, 1, 40, 50, graphPromise(1, 0, openapi.PromiseStateREJECTEDTIMEDOUT)),
+			},
+			want: LostUpdate,
+		},
+		{
+			name: "duplicate completion",
+			history: []store.Operation{
+				graphCreate(1, 0, 0, 10),
+				graphComplete(2, 0, store.Resolve, 20, 30),
+				graphComplete(3, 1, store.Reject, 40, 50),
+			},
+			want: DuplicateCompletion,
+		},
+		{
+			// the resolve overlaps both reads, which see it before they see
+			// the promise pending: 2 wr 3, 3 po 4 and 4 rw 2
+			name: "wr rw cycle",
+			history: []store.Operation{
+				graphCreate(1, 0, 0, 10),
+				graphComplete(2, 1, store.Resolve, 20, 100),
+				graphGet(3, 2, 30, 40, graphPromise(1, 2, openapi.PromiseStateRESOLVED)),
+				graphGet(4, 2, 50, 60, graphPromise(1, 0, openapi.PromiseStatePENDING)),
+			},
+			want: Cycle,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := checkGraph(test.history)
+			if test.want == "" {
+				if !result.Pass {
+					t.Fatalf("correct history rejected: %s\n%s", result, dumpHistory(test.history))
+				}
+				return
+			}
+			for _, a := range result.Anomalies {
+				if a.Kind == test.want {
+					return
+				}
+			}
+			t.Fatalf("expected a %s anomaly, got: %s\n%s", test.want, result, dumpHistory(test.history))
+		})
+	}
+}
+
+// graphValue is the unique value written by an operation.
+func graphValue(opID int) openapi.PromiseValue {
+	return openapi.PromiseValue{
+		Data: utils.ToPointer(base64.StdEncoding.EncodeToString(store.EncodeValue(opID, 0, nil))),
+	}
+}
+
+// graphPromise is promise 'p' as created by one operation and completed by
+// another, a completion of 0 leaves the value empty.
+func graphPromise(created, completed int, state openapi.PromiseState) *openapi.Promise {
+	p := &openapi.Promise{Id: "p", Param: graphValue(created), State: state, Timeout: fuzzTimeout}
+	if completed != 0 {
+		p.Value = graphValue(completed)
+	}
+	return p
+}
+
+func graphOp(id, client int, api store.API, call, ret int64) store.Operation {
+	return store.Operation{
+		ID:          id,
+		ClientID:    client,
+		API:         api,
+		CallEvent:   fuzzBase.Add(time.Duration(call) * time.Millisecond),
+		ReturnEvent: fuzzBase.Add(time.Duration(ret) * time.Millisecond),
+	}
+}
+
+func graphCreate(id, client int, call, ret int64) store.Operation {
+	op := graphOp(id, client, store.Create, call, ret)
+	param := graphValue(id)
+	op.Input = &openapi.CreatePromiseJSONRequestBody{Id: "p", Param: &param, Timeout: fuzzTimeout}
+	op.Status, op.Code, op.Output = store.Ok, http.StatusCreated, graphPromise(id, 0, openapi.PromiseStatePENDING)
+	return op
+}
+
+// graphComplete completes the promise created by operation 1.
+func graphComplete(id, client int, api store.API, call, ret int64) store.Operation {
+	state := map[store.API]openapi.PromiseState{
+		store.Resolve: openapi.PromiseStateRESOLVED,
+		store.Reject:  openapi.PromiseStateREJECTED,
+	}[api]
+	op := graphOp(id, client, api, call, ret)
+	value := graphValue(id)
+	op.Input = &openapi.CompletePromiseRequestWrapper{
+		Id:      utils.ToPointer("p"),
+		Request: &openapi.PatchPromisesIdJSONRequestBody{State: openapi.PromiseStateComplete(state), Value: &value},
+	}
+	op.Status, op.Code, op.Output = store.Ok, http.StatusCreated, graphPromise(1, id, state)
+	return op
+}
+
+func graphGet(id, client int, call, ret int64, p *openapi.Promise) store.Operation {
+	op := graphOp(id, client, store.Get, call, ret)
+	op.Input = "p"
+	op.Status, op.Code, op.Output = store.Ok, http.StatusOK, p
+	return op
+}
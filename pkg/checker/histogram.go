@@ -0,0 +1,127 @@
+package checker
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketBits controls the precision of the histogram, 11 bits keeps the
+// relative error of any recorded value below 0.1%.
+const subBucketBits = 11
+
+// Histogram is a log-linear histogram in the style of HdrHistogram. Values are
+// grouped into power of two buckets, each split into linear sub buckets, so
+// percentiles are accurate to a fixed relative error without keeping samples.
+type Histogram struct {
+	counts []int64
+	total  int64
+	sum    int64
+	min    int64
+	max    int64
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{
+		counts: make([]int64, 1<<subBucketBits),
+		min:    math.MaxInt64,
+	}
+}
+
+// Record adds a single latency to the histogram.
+func (h *Histogram) Record(d time.Duration) {
+	v := int64(d)
+	if v < 0 {
+		v = 0
+	}
+
+	i := bucketIndex(v)
+	if i >= len(h.counts) {
+		grown := make([]int64, i+1)
+		copy(grown, h.counts)
+		h.counts = grown
+	}
+
+	h.counts[i]++
+	h.total++
+	h.sum += v
+	if v < h.min {
+		h.min = v
+	}
+	if v > h.max {
+		h.max = v
+	}
+}
+
+func (h *Histogram) Count() int64 {
+	return h.total
+}
+
+func (h *Histogram) Min() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.min)
+}
+
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.max)
+}
+
+func (h *Histogram) Mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.sum / h.total)
+}
+
+// Percentile returns the value at or below which p (0 < p <= 1) of all recorded values fall.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+
+	target := int64(math.Ceil(float64(h.total) * p))
+	if target < 1 {
+		target = 1
+	}
+
+	var seen int64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= target {
+			v := highestEquivalentValue(i)
+			if v > h.max {
+				v = h.max
+			}
+			if v < h.min {
+				v = h.min
+			}
+			return time.Duration(v)
+		}
+	}
+
+	return time.Duration(h.max)
+}
+
+// bucketIndex maps a value to its slot; values below 2^subBucketBits are exact.
+func bucketIndex(v int64) int {
+	if v < 1<<subBucketBits {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits
+	sub := v >> shift
+	half := int64(1) << (subBucketBits - 1)
+	return int(int64(1)<<subBucketBits + int64(shift-1)*half + (sub - half))
+}
+
+func highestEquivalentValue(i int) int64 {
+	if i < 1<<subBucketBits {
+		return int64(i)
+	}
+	half := 1 << (subBucketBits - 1)
+	offset := i - 1<<subBucketBits
+	shift := offset/half + 1
+	sub := int64(offset%half + half)
+	return (sub << shift) + (int64(1) << shift) - 1
+}
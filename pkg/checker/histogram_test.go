@@ -0,0 +1,67 @@
+package checker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramExact(t *testing.T) {
+	h := NewHistogram()
+	if h.Percentile(0.5) != 0 || h.Min() != 0 || h.Mean() != 0 {
+		t.Fatalf("expected an empty histogram to report zeroes")
+	}
+
+	// values below 2^subBucketBits have a bucket each
+	for v := 1; v <= 1000; v++ {
+		h.Record(time.Duration(v))
+	}
+	for _, c := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0.001, 1}, {0.5, 500}, {0.99, 990}, {0.999, 999}, {1, 1000},
+	} {
+		if got := h.Percentile(c.p); got != c.want {
+			t.Fatalf("expected p%v to be %v, got %v", c.p*100, c.want, got)
+		}
+	}
+	if h.Count() != 1000 || h.Min() != 1 || h.Max() != 1000 || h.Mean() != 500 {
+		t.Fatalf("expected count 1000, min 1, max 1000 and mean 500, got %d, %v, %v and %v", h.Count(), h.Min(), h.Max(), h.Mean())
+	}
+}
+
+func TestHistogramRelativeError(t *testing.T) {
+	h := NewHistogram()
+	for v := 1; v <= 1000; v++ {
+		h.Record(time.Duration(v) * time.Millisecond)
+	}
+	for _, c := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0.5, 500 * time.Millisecond}, {0.9, 900 * time.Millisecond}, {0.99, 990 * time.Millisecond}, {1, time.Second},
+	} {
+		got := h.Percentile(c.p)
+		if got < c.want || got-c.want > c.want/1000 {
+			t.Fatalf("expected p%v within 0.1%% above %v, got %v", c.p*100, c.want, got)
+		}
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	// every value falls in a bucket whose highest equivalent value is at
+	// most 0.1% above it, and that value falls in the same bucket
+	for v := int64(0); v < 1<<40; v = v*3/2 + 1 {
+		i := bucketIndex(v)
+		high := highestEquivalentValue(i)
+		if high < v || high-v > v/1000 {
+			t.Fatalf("value %d in bucket %d with highest equivalent value %d", v, i, high)
+		}
+		if bucketIndex(high) != i {
+			t.Fatalf("highest equivalent value %d of bucket %d falls in bucket %d", high, i, bucketIndex(high))
+		}
+		if i > 0 && highestEquivalentValue(i-1) >= v {
+			t.Fatalf("value %d also fits bucket %d", v, i-1)
+		}
+	}
+}
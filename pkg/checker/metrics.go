@@ -0,0 +1,262 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+// percentiles reported for every api.
+var percentiles = []struct {
+	name string
+	p    float64
+}{
+	{"p50", 0.50},
+	{"p75", 0.75},
+	{"p90", 0.90},
+	{"p95", 0.95},
+	{"p99", 0.99},
+	{"p99.9", 0.999},
+}
+
+// Metrics is the performance breakdown of a set of operations.
+type Metrics struct {
+	API         string              `json:"api"`
+	Count       int                 `json:"count"`
+	Ok          int                 `json:"ok"`
+	Fail        int                 `json:"fail"`
+	Errors      int                 `json:"errors"`
+	Min         Duration            `json:"min"`
+	Max         Duration            `json:"max"`
+	Mean        Duration            `json:"mean"`
+	Percentiles map[string]Duration `json:"percentiles"`
+	RPS         float64             `json:"rps"`
+	StatusCodes map[int]int         `json:"statusCodes"`
+	// BytesSent and BytesReceived are the request and response sizes on the wire.
+	BytesSent     int64 `json:"bytesSent"`
+	BytesReceived int64 `json:"bytesReceived"`
+}
+
+// Performance holds the overall metrics of a run and the metrics of every api.
+type Performance struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Duration Duration  `json:"duration"`
+	Overall  Metrics   `json:"overall"`
+	APIs     []Metrics `json:"apis"`
+}
+
+// Duration marshals to json as fractional milliseconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(float64(d) / float64(time.Millisecond))
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var ms float64
+	if err := json.Unmarshal(b, &ms); err != nil {
+		return err
+	}
+	*d = Duration(ms * float64(time.Millisecond))
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// NewPerformance computes overall and per api metrics for the history.
+func NewPerformance(history []store.Operation) *Performance {
+	perf := &Performance{}
+	if len(history) == 0 {
+		return perf
+	}
+
+	perf.Start, perf.End = history[0].CallEvent, history[0].ReturnEvent
+	for i := range history {
+		if history[i].CallEvent.Before(perf.Start) {
+			perf.Start = history[i].CallEvent
+		}
+		if history[i].ReturnEvent.After(perf.End) {
+			perf.End = history[i].ReturnEvent
+		}
+	}
+	window := perf.End.Sub(perf.Start)
+	perf.Duration = Duration(window)
+
+	overall := newMetricsBuilder("ALL")
+	byAPI := map[store.API]*metricsBuilder{}
+	for i := range history {
+		op := history[i]
+		overall.add(op)
+		if _, ok := byAPI[op.API]; !ok {
+			byAPI[op.API] = newMetricsBuilder(op.API.String())
+		}
+		byAPI[op.API].add(op)
+	}
+
+	apis := make([]store.API, 0, len(byAPI))
+	for api := range byAPI {
+		apis = append(apis, api)
+	}
+	sort.Slice(apis, func(i, j int) bool {
+		return apis[i] < apis[j]
+	})
+
+	perf.Overall = overall.build(window)
+	for _, api := range apis {
+		perf.APIs = append(perf.APIs, byAPI[api].build(window))
+	}
+
+	return perf
+}
+
+// Metrics returns the metrics of a single api, or of the whole run if api is empty.
+func (p *Performance) Metrics(api string) (Metrics, bool) {
+	if api == "" {
+		return p.Overall, p.Overall.Count > 0
+	}
+	for _, m := range p.APIs {
+		if strings.EqualFold(m.API, api) {
+			return m, true
+		}
+	}
+	return Metrics{}, false
+}
+
+// String renders the metrics as a set of text tables.
+func (p *Performance) String() string {
+	rows := append(append([]Metrics{}, p.APIs...), p.Overall)
+
+	build := strings.Builder{}
+
+	build.WriteString("Requests:\n")
+	build.WriteString(fmt.Sprintf("  Total: %v\n", p.Duration))
+	build.WriteString(fmt.Sprintf("  Count: %d\n", p.Overall.Count))
+	build.WriteString(fmt.Sprintf("  Requests/Sec: %.2f\n", p.Overall.RPS))
+	build.WriteString("\n")
+
+	build.WriteString("Latency Distribution:\n")
+	w := tabwriter.NewWriter(&build, 0, 0, 2, ' ', tabwriter.AlignRight)
+	header := []string{"  API", "Count", "Ok", "Fail", "Min", "Mean", "Max"}
+	for _, pc := range percentiles {
+		header = append(header, pc.name)
+	}
+	header = append(header, "RPS")
+	fmt.Fprintln(w, strings.Join(header, "\t")+"\t")
+	for _, m := range rows {
+		row := []string{
+			"  " + m.API,
+			fmt.Sprint(m.Count),
+			fmt.Sprint(m.Ok),
+			fmt.Sprint(m.Fail),
+			fmtDuration(m.Min),
+			fmtDuration(m.Mean),
+			fmtDuration(m.Max),
+		}
+		for _, pc := range percentiles {
+			row = append(row, fmtDuration(m.Percentiles[pc.name]))
+		}
+		row = append(row, fmt.Sprintf("%.2f", m.RPS))
+		fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+	}
+	w.Flush()
+	build.WriteString("\n")
+
+	build.WriteString("Status Code Distribution:\n")
+	for _, m := range rows {
+		codes := make([]int, 0, len(m.StatusCodes))
+		for code := range m.StatusCodes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+
+		parts := make([]string, 0, len(codes))
+		for _, code := range codes {
+			parts = append(parts, fmt.Sprintf("%d=%d", code, m.StatusCodes[code]))
+		}
+		build.WriteString(fmt.Sprintf("  %s: %s\n", m.API, strings.Join(parts, " ")))
+	}
+
+	return build.String()
+}
+
+// JSON renders the metrics in json form.
+func (p *Performance) JSON() (string, error) {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+type metricsBuilder struct {
+	api         string
+	hist        *Histogram
+	ok          int
+	fail        int
+	errors      int
+	statusCodes map[int]int
+	sent        int64
+	received    int64
+}
+
+func newMetricsBuilder(api string) *metricsBuilder {
+	return &metricsBuilder{
+		api:         api,
+		hist:        NewHistogram(),
+		statusCodes: map[int]int{},
+	}
+}
+
+func (b *metricsBuilder) add(op store.Operation) {
+	b.hist.Record(op.ReturnEvent.Sub(op.CallEvent))
+	switch op.Status {
+	case store.Ok:
+		b.ok++
+	case store.Fail:
+		b.fail++
+	}
+	if isError(op) {
+		b.errors++
+	}
+	if op.Status != store.Invoke {
+		b.statusCodes[op.Code]++
+	}
+	b.sent += int64(op.RequestBytes)
+	b.received += int64(op.ResponseBytes)
+}
+
+func (b *metricsBuilder) build(window time.Duration) Metrics {
+	m := Metrics{
+		API:           b.api,
+		Count:         int(b.hist.Count()),
+		Ok:            b.ok,
+		Fail:          b.fail,
+		Errors:        b.errors,
+		Min:           Duration(b.hist.Min()),
+		Max:           Duration(b.hist.Max()),
+		Mean:          Duration(b.hist.Mean()),
+		Percentiles:   map[string]Duration{},
+		StatusCodes:   b.statusCodes,
+		BytesSent:     b.sent,
+		BytesReceived: b.received,
+	}
+	for _, pc := range percentiles {
+		m.Percentiles[pc.name] = Duration(b.hist.Percentile(pc.p))
+	}
+	if window > 0 {
+		m.RPS = float64(m.Count) / window.Seconds()
+	}
+	return m
+}
+
+func fmtDuration(d Duration) string {
+	return time.Duration(d).Round(time.Microsecond).String()
+}
@@ -1,6 +1,8 @@
 package checker
 
 import (
+	"bytes"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"net/http"
@@ -16,23 +18,40 @@ import (
 // A Model is a sequential specification of the durable promise system.
 type DurablePromiseModel struct {
 	SequentialSpec map[store.API]StepVerifier
+
+	// Initial is the state of the server before the run, it is empty unless
+	// the model was bootstrapped from a snapshot.
+	Initial State
+}
+
+// modelConfig is what the model knows about the server besides the history.
+type modelConfig struct {
+	// initial is the state of the server before the run.
+	initial State
+	// clock bounds the offset of the server clock, without it a promise may
+	// time out at any time.
+	clock *Clock
 }
 
-func newDurablePromiseModel() *DurablePromiseModel {
+func newDurablePromiseModel(config modelConfig) *DurablePromiseModel {
 	return &DurablePromiseModel{
+		Initial: config.initial,
 		SequentialSpec: map[store.API]StepVerifier{
-			store.Search:  newSearchPromiseVerifier(),
-			store.Get:     newGetPromiseVerifier(),
-			store.Create:  newCreatePromiseVerifier(),
-			store.Cancel:  newCompletePromiseVerifier(),
-			store.Resolve: newCompletePromiseVerifier(),
-			store.Reject:  newCompletePromiseVerifier(),
+			store.Search:  newSearchPromiseVerifier(config.clock),
+			store.Get:     newGetPromiseVerifier(config.clock),
+			store.Create:  newCreatePromiseVerifier(config.clock),
+			store.Cancel:  newCompletePromiseVerifier(config.clock),
+			store.Resolve: newCompletePromiseVerifier(config.clock),
+			store.Reject:  newCompletePromiseVerifier(config.clock),
 		},
 	}
 }
 
 func (m *DurablePromiseModel) Init() State {
-	return make(State, 0)
+	if m.Initial == nil {
+		return make(State, 0)
+	}
+	return copyState(m.Initial)
 }
 
 func (m *DurablePromiseModel) Step(state State, input, output event) (State, error) {
@@ -40,17 +59,61 @@ func (m *DurablePromiseModel) Step(state State, input, output event) (State, err
 	if !ok {
 		return state, fmt.Errorf("unexpected operation '%d'", input.API)
 	}
+	if input.fuzz != nil {
+		return verifyFuzz(verif, state, input, output)
+	}
 	return verif.Verify(state, input, output)
 }
 
+// verifyFuzz checks malformed input is rejected with a correct client error
+// without changing the state. Edge cases that are valid input may be accepted
+// and are then verified like any other operation.
+func verifyFuzz(verif StepVerifier, state State, req, resp event) (State, error) {
+	fuzz := req.fuzz
+	if !isValidResponse(resp.status) {
+		return state, fmt.Errorf("%s: operation has unexpected status '%d'", fuzz.Name, resp.status)
+	}
+	if resp.code >= http.StatusInternalServerError {
+		return state, fmt.Errorf("%s: got server error '%d': %v", fuzz.Name, resp.code, errorMessage(resp))
+	}
+
+	if resp.status == store.Ok {
+		if fuzz.MayAccept {
+			return verif.Verify(state, req, resp)
+		}
+		return state, fmt.Errorf("%s: invalid input accepted with '%d'", fuzz.Name, resp.code)
+	}
+
+	var expected bool
+	for _, code := range fuzz.Reject {
+		if code == resp.code {
+			expected = true
+			break
+		}
+	}
+	if !expected {
+		return state, fmt.Errorf("%s: expected one of %v, got '%d': %v", fuzz.Name, fuzz.Reject, resp.code, errorMessage(resp))
+	}
+
+	switch resp.code {
+	case http.StatusNotFound, http.StatusForbidden, http.StatusConflict:
+		// these depend on the state, the regular verifier knows when they are correct
+		return verif.Verify(state, req, resp)
+	default:
+		return state, nil
+	}
+}
+
 type StepVerifier interface {
 	Verify(st State, in event, out event) (State, error)
 }
 
-type SearchPromiseVerifier struct{}
+type SearchPromiseVerifier struct {
+	clock *Clock
+}
 
-func newSearchPromiseVerifier() *SearchPromiseVerifier {
-	return &SearchPromiseVerifier{}
+func newSearchPromiseVerifier(clock *Clock) *SearchPromiseVerifier {
+	return &SearchPromiseVerifier{clock: clock}
 }
 
 func (v *SearchPromiseVerifier) Verify(state State, req, resp event) (State, error) {
@@ -62,40 +125,42 @@ func (v *SearchPromiseVerifier) Verify(state State, req, resp event) (State, err
 	if !ok {
 		return state, errors.New("res.Value not of type *openapi.SearchPromisesParams")
 	}
+
+	if resp.status != store.Ok {
+		return state, fmt.Errorf("expected '%d', got '%d': %v", store.Ok, resp.status, errorMessage(resp))
+	}
+
 	respObj, ok := resp.value.(*openapi.SearchPromisesResponseObj)
 	if !ok {
 		return state, errors.New("res.Value not of type *openapi.SearchPromiseResponse")
 	}
-
-	if resp.status != store.Ok {
-		return state, fmt.Errorf("expected '%d', got '%d'", store.Ok, resp.status)
-	}
 	if resp.code != http.StatusOK {
 		return state, fmt.Errorf("expected '%d', got '%d'", http.StatusOK, resp.code)
 	}
 
-	localResults := state.Search(string(*reqObj.State))
-	serverResults := *respObj.Promises
-	sort.Slice(localResults, func(i, j int) bool {
-		return localResults[i].Id < localResults[j].Id
-	})
+	w := v.clock.window(req, resp)
+	expected, optional := state.SearchAt(utils.SafeDereference(reqObj.Id), string(*reqObj.State), w)
+	serverResults := utils.SafeDereference(respObj.Promises)
 
-	sort.Slice(serverResults, func(i, j int) bool {
-		return serverResults[i].Id < serverResults[j].Id
-	})
-
-	if err := deepEqualPromiseList(state, localResults, serverResults); err != nil {
+	if err := equalSearchResults(expected, optional, serverResults, w); err != nil {
 		return state, fmt.Errorf("got mistmatched promises search results: %v", err)
 	}
 
-	// state does not change with read operations
+	// reads do not change the state, but may observe promises time out
+	for i := range serverResults {
+		if local, err := state.Get(serverResults[i].Id); err == nil {
+			state = timeOut(state, local, &serverResults[i])
+		}
+	}
 	return state, nil
 }
 
-type GetPromiseVerifier struct{}
+type GetPromiseVerifier struct {
+	clock *Clock
+}
 
-func newGetPromiseVerifier() *GetPromiseVerifier {
-	return &GetPromiseVerifier{}
+func newGetPromiseVerifier(clock *Clock) *GetPromiseVerifier {
+	return &GetPromiseVerifier{clock: clock}
 }
 
 func (v *GetPromiseVerifier) Verify(state State, req, resp event) (State, error) {
@@ -107,10 +172,6 @@ func (v *GetPromiseVerifier) Verify(state State, req, resp event) (State, error)
 	if !ok {
 		return state, errors.New("res.Value not of type string")
 	}
-	respObj, ok := resp.value.(*openapi.Promise)
-	if !ok {
-		return state, errors.New("res.Value not of type *openapi.Promise")
-	}
 
 	local, err := state.Get(reqObj)
 	if err != nil {
@@ -121,24 +182,31 @@ func (v *GetPromiseVerifier) Verify(state State, req, resp event) (State, error)
 	}
 
 	if resp.status != store.Ok {
-		return state, fmt.Errorf("expected '%d', got '%d'", store.Ok, resp.status)
+		return state, fmt.Errorf("expected '%d', got '%d': %v", store.Ok, resp.status, errorMessage(resp))
+	}
+
+	respObj, ok := resp.value.(*openapi.Promise)
+	if !ok {
+		return state, errors.New("res.Value not of type *openapi.Promise")
 	}
 	if resp.code != http.StatusOK {
 		return state, fmt.Errorf("expected '%d', got '%d'", http.StatusOK, resp.code)
 	}
 
-	if err = deepEqualPromise(state, local, respObj); err != nil {
+	if err = deepEqualPromise(local, respObj, v.clock.window(req, resp)); err != nil {
 		return state, fmt.Errorf("got incorrect promise result: %v", err)
 	}
 
-	// state does not change with read operations
-	return state, nil
+	// reads do not change the state, but may observe the promise time out
+	return timeOut(state, local, respObj), nil
 }
 
-type CreatePromiseVerifier struct{}
+type CreatePromiseVerifier struct {
+	clock *Clock
+}
 
-func newCreatePromiseVerifier() *CreatePromiseVerifier {
-	return &CreatePromiseVerifier{}
+func newCreatePromiseVerifier(clock *Clock) *CreatePromiseVerifier {
+	return &CreatePromiseVerifier{clock: clock}
 }
 
 func (v *CreatePromiseVerifier) Verify(state State, req, resp event) (State, error) {
@@ -150,27 +218,41 @@ func (v *CreatePromiseVerifier) Verify(state State, req, resp event) (State, err
 	if !ok {
 		return state, errors.New("req.Value not of type *openapi.CreatePromiseRequest")
 	}
-	respObj, ok := resp.value.(*openapi.Promise)
-	if !ok {
-		return state, errors.New("resp.Value not of type *openapi.Promise")
-	}
 
 	if resp.status == store.Fail {
 		if resp.code == http.StatusConflict && state.Exists(reqObj.Id) {
 			return state, nil
 		}
-		return state, fmt.Errorf("got an unexpected failure status code '%d", resp.code)
+		return state, fmt.Errorf("got an unexpected failure status code '%d': %v", resp.code, errorMessage(resp))
 	}
 
 	if resp.status != store.Ok {
 		return state, fmt.Errorf("expected '%d', got '%d'", store.Ok, resp.status)
 	}
+
+	respObj, ok := resp.value.(*openapi.Promise)
+	if !ok {
+		return state, errors.New("resp.Value not of type *openapi.Promise")
+	}
 	if resp.code != http.StatusCreated && resp.code != http.StatusOK {
 		return state, fmt.Errorf("expected '%d' or '%d', got '%d'", http.StatusCreated, http.StatusOK, resp.code)
 	}
 	if respObj.State != openapi.PromiseStatePENDING {
 		return state, fmt.Errorf("expected '%s', got '%s'", openapi.PromiseStatePENDING, respObj.State)
 	}
+	if local, err := state.Get(reqObj.Id); err == nil {
+		// an idempotent create returns the promise as it is
+		if err := deepEqualPromise(local, respObj, v.clock.window(req, resp)); err != nil {
+			return state, fmt.Errorf("got incorrect promise result: %v", err)
+		}
+		return state, nil
+	}
+	if err := equalCreated(reqObj, respObj); err != nil {
+		return state, err
+	}
+	if w := v.clock.window(req, resp); respObj.CreatedOn != nil && !w.contains(int64(*respObj.CreatedOn)) {
+		return state, fmt.Errorf("expected 'CreatedOn' between %d and %d, got %d", w.earliest, w.latest, *respObj.CreatedOn)
+	}
 
 	newState := utils.DeepCopy(state)
 	newState.Set(respObj.Id, respObj)
@@ -178,10 +260,12 @@ func (v *CreatePromiseVerifier) Verify(state State, req, resp event) (State, err
 	return newState, nil
 }
 
-type CompletePromiseVerifier struct{}
+type CompletePromiseVerifier struct {
+	clock *Clock
+}
 
-func newCompletePromiseVerifier() *CompletePromiseVerifier {
-	return &CompletePromiseVerifier{}
+func newCompletePromiseVerifier(clock *Clock) *CompletePromiseVerifier {
+	return &CompletePromiseVerifier{clock: clock}
 }
 
 func (v *CompletePromiseVerifier) Verify(state State, req, resp event) (State, error) {
@@ -193,30 +277,65 @@ func (v *CompletePromiseVerifier) Verify(state State, req, resp event) (State, e
 	if !ok {
 		return state, errors.New("req.Value not of type *simulator.CompletePromiseRequestWrapper")
 	}
-	respObj, ok := resp.value.(*openapi.Promise)
-	if !ok {
-		return state, errors.New("resp.Value not of type *openapi.Promise")
-	}
+
+	w := v.clock.window(req, resp)
 
 	if resp.status == store.Fail {
 		switch resp.code {
 		case http.StatusForbidden:
-			if state.Completed(*reqObj.Id) || isErrorResponse(respObj) || isTimedOut(respObj.State) {
+			if state.Completed(*reqObj.Id) || state.TimedOut(*reqObj.Id, w.latest) {
 				return state, nil
 			}
-			return state, fmt.Errorf("got an unexpected 403 status: promise not completed")
+			return state, fmt.Errorf("got an unexpected 403 status: promise not completed: %v", errorMessage(resp))
 		case http.StatusNotFound:
 			if !state.Exists(*reqObj.Id) {
 				return state, nil
 			}
-			return state, fmt.Errorf("got an unexpected 404 status code: promise exists")
+			return state, fmt.Errorf("got an unexpected 404 status code: promise exists: %v", errorMessage(resp))
 		default:
-			return state, fmt.Errorf("got an unexpected failure status code '%d", resp.code)
+			return state, fmt.Errorf("got an unexpected failure status code '%d': %v", resp.code, errorMessage(resp))
 		}
 	}
 
-	if resp.code != http.StatusCreated && resp.code != http.StatusOK && isCorrectCompleteState(resp.API, respObj.State) {
-		return state, fmt.Errorf("go an unexpected ok status code '%d", resp.code)
+	if resp.status != store.Ok {
+		return state, fmt.Errorf("expected '%d', got '%d'", store.Ok, resp.status)
+	}
+
+	respObj, ok := resp.value.(*openapi.Promise)
+	if !ok {
+		return state, errors.New("resp.Value not of type *openapi.Promise")
+	}
+
+	local, err := state.Get(*reqObj.Id)
+	if err != nil {
+		return state, fmt.Errorf("got an unexpected ok status code '%d': %v", resp.code, err)
+	}
+
+	switch {
+	case local.State == openapi.PromiseStatePENDING && isCorrectCompleteState(resp.API, respObj.State):
+		if w.passed(local.Timeout) {
+			return state, fmt.Errorf("got '%s' after the timeout %d had passed on the server", respObj.State, local.Timeout)
+		}
+		if body, ok := reqObj.Request.(*openapi.PatchPromisesIdJSONRequestBody); ok && body != nil {
+			if err := equalValue("Value", body.Value, &respObj.Value); err != nil {
+				return state, fmt.Errorf("value did not round trip: %v", err)
+			}
+		}
+		if err := checkCompletedOn(respObj, w); err != nil {
+			return state, fmt.Errorf("got incorrect promise result: %v", err)
+		}
+		// everything but the state, value and completedOn stays as it was
+		expected := *local
+		expected.State, expected.Value, expected.CompletedOn = respObj.State, respObj.Value, respObj.CompletedOn
+		if err := deepEqualPromise(&expected, respObj, w); err != nil {
+			return state, fmt.Errorf("got incorrect promise result: %v", err)
+		}
+	default:
+		// an idempotent complete, or one that finds the promise timed out,
+		// returns the promise as it is
+		if err := deepEqualPromise(local, respObj, w); err != nil {
+			return state, fmt.Errorf("got incorrect promise result: %v", err)
+		}
 	}
 
 	newState := utils.DeepCopy(state)
@@ -228,25 +347,64 @@ func (v *CompletePromiseVerifier) Verify(state State, req, resp event) (State, e
 // State holds the expectation of the client
 type State map[string]*openapi.Promise
 
+// NewState returns the state of a server holding the given promises.
+func NewState(promises []openapi.Promise) State {
+	s := make(State, len(promises))
+	for i := range promises {
+		p := promises[i]
+		s.Set(p.Id, &p)
+	}
+	return s
+}
+
 func (s State) Set(key string, val *openapi.Promise) {
 	s[key] = val
 }
 
-func (s State) Search(stateParam string) []openapi.Promise {
+// Search returns the promises whose id matches the pattern, where '*' matches
+// any sequence of characters, and whose state matches the state param.
+func (s State) Search(idParam, stateParam string) []openapi.Promise {
 	filter := make([]openapi.Promise, 0)
 	for _, promise := range s {
-		if promise == nil && promise.State == "" {
+		if promise == nil || promise.State == "" {
 			continue
 		}
-		if strings.EqualFold(stateParam, string(openapi.PromiseStateREJECTED)) && isRejectedState(promise.State) {
+		if matchSearch(idParam, stateParam, promise) {
 			filter = append(filter, *promise)
+		}
+	}
+	return filter
+}
+
+// SearchAt is Search at a server time within the window. Pending promises
+// whose timeout has passed by then are expected timed out, those whose
+// timeout may have passed are optional as they may be found in either state.
+func (s State) SearchAt(idParam, stateParam string, w window) (expected, optional []openapi.Promise) {
+	for _, promise := range s {
+		if promise == nil || promise.State == "" {
 			continue
 		}
-		if strings.EqualFold(stateParam, string(promise.State)) {
-			filter = append(filter, *promise)
+		if promise.State != openapi.PromiseStatePENDING || !w.mayHavePassed(promise.Timeout) {
+			if matchSearch(idParam, stateParam, promise) {
+				expected = append(expected, *promise)
+			}
+			continue
+		}
+
+		// the promise is compared as it is, a timed out result is checked
+		// against the window like any other read
+		timedOut := *promise
+		timedOut.State = openapi.PromiseStateREJECTEDTIMEDOUT
+		switch {
+		case w.passed(promise.Timeout):
+			if matchSearch(idParam, stateParam, &timedOut) {
+				expected = append(expected, *promise)
+			}
+		case matchSearch(idParam, stateParam, promise) || matchSearch(idParam, stateParam, &timedOut):
+			optional = append(optional, *promise)
 		}
 	}
-	return filter
+	return expected, optional
 }
 
 func (s State) Get(key string) (*openapi.Promise, error) {
@@ -276,6 +434,15 @@ func (s State) Completed(key string) bool {
 	}
 }
 
+// TimedOut reports whether a pending promise could have timed out by the given time in milliseconds.
+func (s State) TimedOut(key string, at int64) bool {
+	val, ok := s[key]
+	if !ok {
+		return false
+	}
+	return val.State == openapi.PromiseStatePENDING && val.Timeout <= at
+}
+
 func (s State) String() string {
 	// sorts key for consistent output
 	keys := make([]string, 0, len(s))
@@ -303,12 +470,42 @@ func (s State) String() string {
 // utils
 //
 
-func isValidResponse(stat store.Status) bool {
-	return stat == store.Ok || stat == store.Fail
+// matchSearch reports whether a search for the id pattern and state finds the promise.
+func matchSearch(idParam, stateParam string, promise *openapi.Promise) bool {
+	if !matchID(idParam, promise.Id) {
+		return false
+	}
+	if strings.EqualFold(stateParam, string(openapi.PromiseStateREJECTED)) && isRejectedState(promise.State) {
+		return true
+	}
+	return strings.EqualFold(stateParam, string(promise.State))
 }
 
-func isTimedOut(state openapi.PromiseState) bool {
-	return state == openapi.PromiseStateREJECTEDTIMEDOUT
+// matchID matches an id against a search pattern, an empty pattern matches every id.
+func matchID(pattern, id string) bool {
+	if pattern == "" {
+		return true
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(id, parts[0]) {
+		return false
+	}
+	id = id[len(parts[0]):]
+	if len(parts) == 1 {
+		return id == ""
+	}
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(id, part)
+		if i < 0 {
+			return false
+		}
+		id = id[i+len(part):]
+	}
+	return strings.HasSuffix(id, parts[len(parts)-1])
+}
+
+func isValidResponse(stat store.Status) bool {
+	return stat == store.Ok || stat == store.Fail
 }
 
 func isCorrectCompleteState(api store.API, state openapi.PromiseState) bool {
@@ -333,29 +530,56 @@ func isRejectedState(state openapi.PromiseState) bool {
 	}
 }
 
-func deepEqualPromiseList(state State, local, external []openapi.Promise) error {
-	if len(local) != len(external) {
-		return fmt.Errorf("expected '%v' promises, got '%v'instead", len(local), len(external))
+// equalSearchResults checks a search found every expected promise, and no
+// promise that is neither expected nor optional.
+func equalSearchResults(expected, optional, external []openapi.Promise, w window) error {
+	local := make(map[string]*openapi.Promise, len(expected)+len(optional))
+	for i := range optional {
+		local[optional[i].Id] = &optional[i]
+	}
+	for i := range expected {
+		local[expected[i].Id] = &expected[i]
+	}
+
+	if len(external) < len(expected) || len(external) > len(expected)+len(optional) {
+		if len(optional) == 0 {
+			return fmt.Errorf("expected '%v' promises, got '%v' instead", len(expected), len(external))
+		}
+		return fmt.Errorf("expected '%v' to '%v' promises, got '%v' instead", len(expected), len(expected)+len(optional), len(external))
 	}
-	for i := range local {
-		err := deepEqualPromise(state, &local[i], &external[i])
-		if err != nil {
+
+	found := make(map[string]bool, len(external))
+	for i := range external {
+		p, ok := local[external[i].Id]
+		if !ok || found[p.Id] {
+			return fmt.Errorf("got unexpected promise '%v'", external[i].Id)
+		}
+		found[p.Id] = true
+		if err := deepEqualPromise(p, &external[i], w); err != nil {
 			return err
 		}
 	}
+	for _, p := range expected {
+		if !found[p.Id] {
+			return fmt.Errorf("expected promise '%v', it was not found", p.Id)
+		}
+	}
 	return nil
 }
 
-func deepEqualPromise(state State, local, external *openapi.Promise) error {
-	// intentionally ignore createdOn and completedOn fields
+func deepEqualPromise(local, external *openapi.Promise, w window) error {
+	// createdOn and completedOn never change once set
 	if !reflect.DeepEqual(local.CreatedOn, external.CreatedOn) {
-		return fmt.Errorf("expected 'CreatedOn' %v, got %v", local.CreatedOn, external.CreatedOn)
+		return fmt.Errorf("expected 'CreatedOn' %s, got %s", formatStamp(local.CreatedOn), formatStamp(external.CreatedOn))
+	}
+	if local.State != openapi.PromiseStatePENDING && !reflect.DeepEqual(local.CompletedOn, external.CompletedOn) {
+		return fmt.Errorf("expected 'CompletedOn' %s, got %s", formatStamp(local.CompletedOn), formatStamp(external.CompletedOn))
 	}
 	if !reflect.DeepEqual(local.Id, external.Id) {
 		return fmt.Errorf("expected 'Id' %v, got %v", local.Id, external.Id)
 	}
-	if !reflect.DeepEqual(local.Param, external.Param) {
-		return fmt.Errorf("expected 'Param' %v, got %v", local.Param, external.Param)
+	if err := equalValue("Param", &local.Param, &external.Param); err != nil {
+		return err
 	}
 	if !reflect.DeepEqual(local.Tags, external.Tags) {
 		return fmt.Errorf("expected 'Tags' %v, got %v", local.Tags, external.Tags)
@@ -363,8 +587,8 @@ func deepEqualPromise(state State, local, external *openapi.Promise) error {
 	if !reflect.DeepEqual(local.Timeout, external.Timeout) {
 		return fmt.Errorf("expected 'Timeout' %v, got %v", local.Timeout, external.Timeout)
 	}
-	if !reflect.DeepEqual(local.Value, external.Value) {
-		return fmt.Errorf("expected 'Value' %v, got %v", local.Value, external.Value)
+	if err := equalValue("Value", &local.Value, &external.Value); err != nil {
+		return err
 	}
 
 	// A client and a server may have clocks that are out of sync with each other. The client's
@@ -376,24 +600,153 @@ func deepEqualPromise(state State, local, external *openapi.Promise) error {
 	// time to send a request when the deadline has already passed on the server side. To avoid
 	// unpredictable behavior, the server's clock is the definitive source of time for any timeouts.
 	// This keeps the timing consistent from the perspective of the server, which helps ensure
-	// reliability in the system.
-	if external.State != openapi.PromiseStateREJECTEDTIMEDOUT {
-		if !reflect.DeepEqual(local.State, external.State) {
-			return fmt.Errorf("expected'State' %v, got %v", local.State, external.State)
+	// reliability in the system. The harness estimates the offset of the server clock, so a
+	// pending promise may be observed timed out only once its timeout may have passed on the
+	// server, and must be observed timed out once it has passed for certain.
+	if local.State == openapi.PromiseStatePENDING {
+		switch {
+		case external.State == openapi.PromiseStateREJECTEDTIMEDOUT && !w.mayHavePassed(local.Timeout):
+			return fmt.Errorf("got 'State' %v, timeout %v could not have passed on the server by %v", external.State, local.Timeout, w.latest)
+		case external.State == openapi.PromiseStatePENDING && w.passed(local.Timeout):
+			return fmt.Errorf("expected 'State' %v, timeout %v had passed on the server by %v", openapi.PromiseStateREJECTEDTIMEDOUT, local.Timeout, w.earliest)
+		case external.State == openapi.PromiseStateREJECTEDTIMEDOUT:
+			return checkCompletedOn(external, w)
+		case external.State == openapi.PromiseStatePENDING:
+			if external.CompletedOn != nil {
+				return fmt.Errorf("expected no 'CompletedOn', got %d", *external.CompletedOn)
+			}
+			return nil
 		}
-	} else {
-		// if external state is timeout, then local state should update to timedout
-		local.State = external.State
-		state.Set(local.Id, local)
+	}
+	if !reflect.DeepEqual(local.State, external.State) {
+		return fmt.Errorf("expected 'State' %v, got %v", local.State, external.State)
 	}
 
 	return nil
 }
 
-// hack for now to check if received error response
-func isErrorResponse(respObj *openapi.Promise) bool {
-	if respObj.Id == "" && respObj.State == "" {
-		return true
+// timeOut returns the state after a pending promise was observed timed out.
+func timeOut(state State, local, external *openapi.Promise) State {
+	if local.State != openapi.PromiseStatePENDING || external.State != openapi.PromiseStateREJECTEDTIMEDOUT {
+		return state
+	}
+	newState := utils.DeepCopy(state)
+	newState[local.Id].State = openapi.PromiseStateREJECTEDTIMEDOUT
+	if external.CompletedOn != nil {
+		newState[local.Id].CompletedOn = utils.ToPointer(*external.CompletedOn)
+	}
+	return newState
+}
+
+// checkCompletedOn checks the completedOn of a promise completed during the
+// operation, or timed out by its end, could have been read off the server
+// clock at that time.
+func checkCompletedOn(p *openapi.Promise, w window) error {
+	if p.CompletedOn == nil {
+		return nil
+	}
+	at := int64(*p.CompletedOn)
+	if p.CreatedOn != nil && at < int64(*p.CreatedOn) {
+		return fmt.Errorf("expected 'CompletedOn' at or after 'CreatedOn' %d, got %d", *p.CreatedOn, at)
+	}
+	if p.State == openapi.PromiseStateREJECTEDTIMEDOUT {
+		if at > w.latest {
+			return fmt.Errorf("expected 'CompletedOn' by %d, got %d", w.latest, at)
+		}
+		return nil
+	}
+	if !w.contains(at) {
+		return fmt.Errorf("expected 'CompletedOn' between %d and %d, got %d", w.earliest, w.latest, at)
+	}
+	return nil
+}
+
+// formatStamp renders an optional timestamp for error messages.
+func formatStamp(at *int) string {
+	if at == nil {
+		return "none"
+	}
+	return fmt.Sprint(*at)
+}
+
+// equalCreated checks a newly created promise matches the request that created it.
+func equalCreated(req *openapi.CreatePromiseJSONRequestBody, resp *openapi.Promise) error {
+	if req.Id != resp.Id {
+		return fmt.Errorf("expected 'Id' %v, got %v", req.Id, resp.Id)
+	}
+	if err := equalValue("Param", req.Param, &resp.Param); err != nil {
+		return fmt.Errorf("param did not round trip: %v", err)
+	}
+	if err := equalValue("Value", nil, &resp.Value); err != nil {
+		return err
+	}
+	var tags map[string]string
+	if req.Tags != nil {
+		tags = *req.Tags
+	}
+	if len(tags) != len(resp.Tags) {
+		return fmt.Errorf("expected 'Tags' %v, got %v", tags, resp.Tags)
+	}
+	for k, v := range tags {
+		if ev, ok := resp.Tags[k]; !ok || ev != v {
+			return fmt.Errorf("expected 'Tags' %v, got %v", tags, resp.Tags)
+		}
+	}
+	if req.Timeout != resp.Timeout {
+		return fmt.Errorf("expected 'Timeout' %v, got %v", req.Timeout, resp.Timeout)
+	}
+	if resp.CompletedOn != nil {
+		return fmt.Errorf("expected no 'CompletedOn', got %d", *resp.CompletedOn)
+	}
+	return nil
+}
+
+// equalValue compares the data of two values byte for byte along with their
+// headers. A missing value, data or header map equals an empty one.
+func equalValue(field string, local, external *openapi.PromiseValue) error {
+	var l, e openapi.PromiseValue
+	if local != nil {
+		l = *local
+	}
+	if external != nil {
+		e = *external
+	}
+
+	ld, ed := decodeData(l.Data), decodeData(e.Data)
+	if !bytes.Equal(ld, ed) {
+		at := 0
+		for at < len(ld) && at < len(ed) && ld[at] == ed[at] {
+			at++
+		}
+		return fmt.Errorf("expected '%s' of %d bytes, got %d bytes differing from byte %d", field, len(ld), len(ed), at)
+	}
+
+	if len(l.Headers) != len(e.Headers) {
+		return fmt.Errorf("expected '%s' with %d headers, got %d", field, len(l.Headers), len(e.Headers))
+	}
+	for k, v := range l.Headers {
+		if ev, ok := e.Headers[k]; !ok || ev != v {
+			return fmt.Errorf("expected '%s' header '%s' to be %q, got %q", field, k, v, ev)
+		}
+	}
+
+	return nil
+}
+
+// decodeData returns the bytes of base64 encoded data, or the data itself if
+// it is not valid base64.
+func decodeData(data *string) []byte {
+	s := utils.SafeDereference(data)
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return b
+	}
+	return []byte(s)
+}
+
+// errorMessage returns the error payload the server responded with, if any.
+func errorMessage(resp event) string {
+	if errResp, ok := resp.value.(*openapi.ErrorResponse); ok && errResp != nil {
+		return errResp.Message
 	}
-	return false
+	return "no error message"
 }
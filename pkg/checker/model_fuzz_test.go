@@ -0,0 +1,406 @@
+package checker
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anishathalye/porcupine"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
+)
+
+// FuzzCorrectHistories checks that histories produced by a correct durable
+// promise store are accepted by every engine and consistency model.
+func FuzzCorrectHistories(f *testing.F) {
+	addSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		history := generateHistory(&source{data: data})
+
+		if !linearizable(history) {
+			t.Fatalf("correct history rejected by porcupine\n%s", dumpHistory(history))
+		}
+		if result := checkGraph(history); !result.Pass {
+			t.Fatalf("correct history rejected by the graph checker: %s\n%s", result, dumpHistory(history))
+		}
+		for _, m := range ConsistencyModels {
+			if m == Linearizable {
+				continue
+			}
+			result := checkConsistency(m, history, fuzzConfig(history), time.Minute)
+			if !result.Pass || result.Inconclusive {
+				t.Fatalf("correct history rejected: %s\n%s", result, dumpHistory(history))
+			}
+		}
+	})
+}
+
+// FuzzMutatedHistories changes a single field of a single response in a
+// correct history and checks the model rejects it.
+func FuzzMutatedHistories(f *testing.F) {
+	addSeeds(f)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		src := &source{data: data}
+		history := generateHistory(src)
+		desc, ok := mutate(history, src)
+		if !ok {
+			t.Skip("history has no response to mutate")
+		}
+
+		if linearizable(history) {
+			t.Fatalf("%s accepted by porcupine\n%s", desc, dumpHistory(history))
+		}
+		if result := checkConsistency(Sequential, history, fuzzConfig(history), time.Minute); result.Pass {
+			t.Fatalf("%s accepted by the sequential consistency check\n%s", desc, dumpHistory(history))
+		}
+	})
+}
+
+func addSeeds(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})
+	f.Add([]byte("create resolve get search reject cancel"))
+	f.Add([]byte{3, 2, 2, 0, 2, 1, 3, 0, 1, 4, 0, 1, 1, 0, 5, 0, 1, 0, 0, 2, 0, 0, 2, 2, 1, 1, 3, 3, 1, 0, 4, 2})
+	f.Add([]byte(strings.Repeat("\x00\x02\x01\x03\x01\x01\x04\x02", 16)))
+}
+
+//
+// reference store
+//
+
+// fuzzTimeout is far enough in the future that a promise never times out.
+const fuzzTimeout = 2524608000000
+
+// reference is a sequential durable promise store, every history it produces
+// is linearizable by construction.
+type reference struct {
+	promises map[string]*openapi.Promise
+}
+
+// apply applies an operation at the given time in milliseconds, promises time
+// out lazily as operations observe them.
+func (s *reference) apply(api store.API, input interface{}, now int64) (store.Status, int, interface{}) {
+	for _, p := range s.promises {
+		if p.State == openapi.PromiseStatePENDING && p.Timeout <= now {
+			p.State = openapi.PromiseStateREJECTEDTIMEDOUT
+			p.CompletedOn = utils.ToPointer(int(p.Timeout))
+		}
+	}
+
+	switch api {
+	case store.Search:
+		params := input.(*openapi.SearchPromisesParams)
+		promises := []openapi.Promise{}
+		for _, p := range s.promises {
+			param := string(*params.State)
+			if strings.EqualFold(param, string(p.State)) ||
+				(strings.EqualFold(param, string(openapi.PromiseStateREJECTED)) && isRejectedState(p.State)) {
+				promises = append(promises, clonePromise(p))
+			}
+		}
+		return store.Ok, http.StatusOK, &openapi.SearchPromisesResponseObj{Promises: &promises}
+
+	case store.Get:
+		p, ok := s.promises[input.(string)]
+		if !ok {
+			return store.Fail, http.StatusNotFound, &openapi.ErrorResponse{Code: http.StatusNotFound, Message: "not found"}
+		}
+		out := clonePromise(p)
+		return store.Ok, http.StatusOK, &out
+
+	case store.Create:
+		req := input.(*openapi.CreatePromiseJSONRequestBody)
+		if _, ok := s.promises[req.Id]; ok {
+			return store.Fail, http.StatusConflict, &openapi.ErrorResponse{Code: http.StatusConflict, Message: "already exists"}
+		}
+		p := &openapi.Promise{
+			Id:        req.Id,
+			Param:     *req.Param,
+			State:     openapi.PromiseStatePENDING,
+			Timeout:   req.Timeout,
+			CreatedOn: utils.ToPointer(int(now)),
+		}
+		if req.Tags != nil {
+			p.Tags = *req.Tags
+		}
+		s.promises[req.Id] = p
+		out := clonePromise(p)
+		return store.Ok, http.StatusCreated, &out
+
+	default:
+		req := input.(*openapi.CompletePromiseRequestWrapper)
+		body := req.Request.(*openapi.PatchPromisesIdJSONRequestBody)
+		p, ok := s.promises[*req.Id]
+		if !ok {
+			return store.Fail, http.StatusNotFound, &openapi.ErrorResponse{Code: http.StatusNotFound, Message: "not found"}
+		}
+		if p.State != openapi.PromiseStatePENDING {
+			return store.Fail, http.StatusForbidden, &openapi.ErrorResponse{Code: http.StatusForbidden, Message: "already completed"}
+		}
+		p.State = openapi.PromiseState(body.State)
+		p.Value = *body.Value
+		p.CompletedOn = utils.ToPointer(int(now))
+		out := clonePromise(p)
+		return store.Ok, http.StatusCreated, &out
+	}
+}
+
+func clonePromise(p *openapi.Promise) openapi.Promise {
+	c := *p
+	c.Param = cloneValue(p.Param)
+	c.Value = cloneValue(p.Value)
+	if p.Tags != nil {
+		c.Tags = make(map[string]string, len(p.Tags))
+		for k, v := range p.Tags {
+			c.Tags[k] = v
+		}
+	}
+	return c
+}
+
+func cloneValue(v openapi.PromiseValue) openapi.PromiseValue {
+	c := openapi.PromiseValue{}
+	if v.Data != nil {
+		c.Data = utils.ToPointer(*v.Data)
+	}
+	if v.Headers != nil {
+		c.Headers = make(map[string]string, len(v.Headers))
+		for k, h := range v.Headers {
+			c.Headers[k] = h
+		}
+	}
+	return c
+}
+
+//
+// history generation
+//
+
+// source hands out fuzz input, it keeps returning zeroes once exhausted so
+// the mutation drawn after the history is still deterministic.
+type source struct {
+	data []byte
+	pos  int
+}
+
+func (s *source) next(n int) int {
+	if s.pos >= len(s.data) || n <= 0 {
+		return 0
+	}
+	b := int(s.data[s.pos])
+	s.pos++
+	return b % n
+}
+
+const (
+	maxFuzzOps     = 64
+	maxFuzzClients = 4
+	maxFuzzIDs     = 4
+	// opSpacing is the time in milliseconds between the points at which
+	// consecutive operations take effect.
+	opSpacing = 10
+)
+
+var fuzzBase = time.UnixMilli(1700000000000)
+
+// generateHistory derives a concurrent history from the fuzz input. The
+// reference store applies operations one after another, each operation is
+// then given a call and return time around the point it took effect such
+// that operations of different clients overlap while operations of the same
+// client never do.
+func generateHistory(src *source) []store.Operation {
+	clients := 1 + src.next(maxFuzzClients)
+	ids := 1 + src.next(maxFuzzIDs)
+	n := len(src.data) / 2
+	if n > maxFuzzOps {
+		n = maxFuzzOps
+	}
+
+	ref := &reference{promises: map[string]*openapi.Promise{}}
+	history := make([]store.Operation, 0, n)
+	for i := 0; i < n; i++ {
+		op := store.Operation{
+			ID:       i + 1,
+			ClientID: i % clients,
+			API:      store.APIs[src.next(len(store.APIs))],
+		}
+		id := fmt.Sprintf("p%d", src.next(ids))
+		at := fuzzBase.Add(time.Duration(i*opSpacing) * time.Millisecond)
+		op.Input = fuzzInput(src, op, id, at)
+		op.Status, op.Code, op.Output = ref.apply(op.API, op.Input, at.UnixMilli())
+		op.ServerDate = at.Truncate(time.Second)
+
+		// jitter stays below half the distance between two operations of the same client
+		jitter := opSpacing * clients / 2
+		op.CallEvent = at.Add(-time.Duration(src.next(jitter)) * time.Millisecond)
+		op.ReturnEvent = at.Add(time.Duration(src.next(jitter)) * time.Millisecond)
+
+		history = append(history, op)
+	}
+
+	return history
+}
+
+func fuzzInput(src *source, op store.Operation, id string, at time.Time) interface{} {
+	switch op.API {
+	case store.Search:
+		states := []openapi.SearchPromisesParamsState{"PENDING", "RESOLVED", "REJECTED"}
+		return &openapi.SearchPromisesParams{Id: utils.ToPointer("*"), State: &states[src.next(len(states))]}
+	case store.Get:
+		return id
+	case store.Create:
+		req := &openapi.CreatePromiseJSONRequestBody{Id: id, Param: fuzzValue(src, op), Timeout: fuzzTimeout}
+		// every other promise times out a few operations after it is created,
+		// derived from the operation so existing corpus entries keep their meaning
+		if op.ID%2 == 0 {
+			req.Timeout = at.Add(time.Duration((1+op.ID%8)*opSpacing) * time.Millisecond).UnixMilli()
+		}
+		if src.next(2) == 1 {
+			req.Tags = &map[string]string{"client": fmt.Sprint(op.ClientID)}
+		}
+		return req
+	default:
+		state := map[store.API]openapi.PromiseStateComplete{
+			store.Resolve: openapi.PromiseStateCompleteRESOLVED,
+			store.Reject:  openapi.PromiseStateCompleteREJECTED,
+			store.Cancel:  openapi.PromiseStateCompleteREJECTEDCANCELED,
+		}[op.API]
+		return &openapi.CompletePromiseRequestWrapper{
+			Id:      utils.ToPointer(id),
+			Request: &openapi.PatchPromisesIdJSONRequestBody{State: state, Value: fuzzValue(src, op)},
+		}
+	}
+}
+
+// fuzzValue returns a value unique to the operation, like the harness does
+// with --unique-values.
+func fuzzValue(src *source, op store.Operation) *openapi.PromiseValue {
+	payload := make([]byte, src.next(8))
+	for i := range payload {
+		payload[i] = byte(src.next(256))
+	}
+	value := &openapi.PromiseValue{
+		Data: utils.ToPointer(base64.StdEncoding.EncodeToString(store.EncodeValue(op.ID, op.ClientID, payload))),
+	}
+	if src.next(2) == 1 {
+		value.Headers = map[string]string{"x-op": fmt.Sprint(op.ID)}
+	}
+	return value
+}
+
+//
+// mutations
+//
+
+// mutate changes a single field of a promise in one successful response. Every
+// value is unique, so no order of the operations can explain the change.
+func mutate(history []store.Operation, src *source) (string, bool) {
+	type target struct {
+		op      *store.Operation
+		promise *openapi.Promise
+	}
+	targets := []target{}
+	for i := range history {
+		op := &history[i]
+		if op.Status != store.Ok {
+			continue
+		}
+		switch out := op.Output.(type) {
+		case *openapi.Promise:
+			targets = append(targets, target{op, out})
+		case *openapi.SearchPromisesResponseObj:
+			for j := range *out.Promises {
+				targets = append(targets, target{op, &(*out.Promises)[j]})
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return "", false
+	}
+
+	t := targets[src.next(len(targets))]
+	p := t.promise
+	var field string
+	switch src.next(8) {
+	case 0:
+		field = "id"
+		p.Id += "-mutated"
+	case 1:
+		field = "param"
+		p.Param.Data = utils.ToPointer(base64.StdEncoding.EncodeToString([]byte("mutated")))
+	case 2:
+		field = "value"
+		p.Value.Data = utils.ToPointer(base64.StdEncoding.EncodeToString([]byte("mutated")))
+	case 3:
+		field = "tags"
+		p.Tags = map[string]string{"mutated": "true"}
+	case 4:
+		field = "timeout"
+		p.Timeout++
+	case 5:
+		// an hour is beyond what the Date header allows for
+		field = "createdOn"
+		if p.CreatedOn == nil {
+			return "", false
+		}
+		p.CreatedOn = utils.ToPointer(*p.CreatedOn + int(time.Hour.Milliseconds()))
+	case 6:
+		field = "completedOn"
+		if p.CompletedOn == nil {
+			return "", false
+		}
+		p.CompletedOn = utils.ToPointer(*p.CompletedOn + int(time.Hour.Milliseconds()))
+	default:
+		// a promise seen timed out may as well have been seen pending
+		// while its timeout passed, the change is not always detectable
+		if p.State == openapi.PromiseStateREJECTEDTIMEDOUT {
+			return "", false
+		}
+		field = "state"
+		states := []openapi.PromiseState{}
+		for _, state := range []openapi.PromiseState{
+			openapi.PromiseStatePENDING,
+			openapi.PromiseStateRESOLVED,
+			openapi.PromiseStateREJECTED,
+			openapi.PromiseStateREJECTEDCANCELED,
+		} {
+			if state != p.State {
+				states = append(states, state)
+			}
+		}
+		next := states[src.next(len(states))]
+		p.State = next
+	}
+
+	return fmt.Sprintf("%s of %s response (id=%d)", field, t.op.API, t.op.ID), true
+}
+
+//
+// helpers
+//
+
+// fuzzConfig estimates the clock from the Date headers, createdOn and
+// completedOn of the reference store, which reads its clock exactly when an
+// operation takes effect.
+func fuzzConfig(history []store.Operation) modelConfig {
+	return modelConfig{clock: EstimateClock(history)}
+}
+
+func linearizable(history []store.Operation) bool {
+	return porcupine.CheckEvents(newPorcupineModel(fuzzConfig(history)), makePorcupineEvents(history))
+}
+
+func dumpHistory(history []store.Operation) string {
+	build := strings.Builder{}
+	for _, op := range history {
+		build.WriteString(op.String())
+		build.WriteString("\n")
+	}
+	return build.String()
+}
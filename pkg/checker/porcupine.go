@@ -11,8 +11,8 @@ import (
 )
 
 // newPorcupineModel is being used as a wrapper around the model for its functionality.
-func newPorcupineModel() porcupine.Model {
-	model := newDurablePromiseModel()
+func newPorcupineModel(config modelConfig) porcupine.Model {
+	model := newDurablePromiseModel(config)
 
 	return porcupine.Model{
 		Init: func() interface{} {
@@ -32,23 +32,7 @@ func newPorcupineModel() porcupine.Model {
 			return reflect.DeepEqual(s1, s2)
 		},
 		DescribeOperation: func(input interface{}, output interface{}) string {
-			in := input.(event)
-
-			var param interface{}
-			switch v := in.value.(type) {
-			case *openapi.SearchPromisesParams:
-				param = utils.SafeDereference(v.State)
-			case string:
-				param = v
-			case *openapi.CreatePromiseJSONRequestBody:
-				param = v.Id
-			case *openapi.CompletePromiseRequestWrapper:
-				param = utils.SafeDereference(v.Id)
-			default:
-				return ""
-			}
-
-			return fmt.Sprintf("%s(%v)", in.API.String(), param)
+			return describe(input.(event))
 		},
 		DescribeState: func(state interface{}) string {
 			return state.(State).String()
@@ -57,6 +41,34 @@ func newPorcupineModel() porcupine.Model {
 
 }
 
+// describe renders an operation as API(param) for the visualization and explanations.
+func describe(in event) string {
+	var param interface{}
+	switch v := in.value.(type) {
+	case *openapi.SearchPromisesParams:
+		param = utils.SafeDereference(v.State)
+	case string:
+		param = v
+	case *openapi.CreatePromiseJSONRequestBody:
+		param = v.Id
+	case *openapi.CompletePromiseRequestWrapper:
+		param = utils.SafeDereference(v.Id)
+	default:
+		return ""
+	}
+
+	// fuzzed ids can be huge
+	if id, ok := param.(string); ok && len(id) > maxDescribedID {
+		param = id[:maxDescribedID] + "..."
+	}
+	if in.fuzz != nil {
+		return fmt.Sprintf("%s(%v) [%s]", in.API.String(), param, in.fuzz.Name)
+	}
+	return fmt.Sprintf("%s(%v)", in.API.String(), param)
+}
+
+const maxDescribedID = 64
+
 func makePorcupineEvents(ops []store.Operation) []porcupine.Event {
 	porcupineEvents, events := make([]porcupine.Event, 0), makeEvents(ops)
 
@@ -0,0 +1,237 @@
+package checker
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+// reportStyle is inlined so the report stays a single file without network dependencies.
+const reportStyle = `body{font-family:sans-serif;margin:2em;color:#222}
+h1 .pass{color:#2ca02c}h1 .fail{color:#d62728}
+table{border-collapse:collapse;margin:0.5em 0 1.5em}
+th,td{border:1px solid #ccc;padding:2px 8px;text-align:right}
+th:first-child,td:first-child{text-align:left}
+pre{background:#f6f6f6;padding:1em;overflow-x:auto}
+iframe{width:100%;height:800px;border:1px solid #ccc}
+figure{margin:1em 0}figcaption{font-weight:bold}`
+
+// Report renders a single self contained html page of a run: the verdict,
+// config, failure explanation, charts, per api tables and the porcupine
+// visualization embedded as an iframe.
+func (v *Visualizer) Report(result *Result, config map[string]string, visualization string, history []store.Operation) string {
+	build := strings.Builder{}
+	build.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	build.WriteString("<title>Durable Promise Test Harness Report</title>\n")
+	build.WriteString("<style>" + reportStyle + "</style>\n")
+	build.WriteString("</head>\n<body>\n")
+
+	build.WriteString(reportVerdict(result))
+	build.WriteString(reportConfig(config))
+	build.WriteString(reportRounds(result.Rounds))
+	if result.Explanation != nil {
+		build.WriteString("<h2>Failure Explanation</h2>\n")
+		build.WriteString("<pre>" + html.EscapeString(result.Explanation.String()) + "</pre>\n")
+	}
+
+	if result.Graph != nil {
+		build.WriteString(reportAnomalies(result.Graph))
+	}
+	for _, c := range result.Consistency {
+		build.WriteString(reportConsistency(c))
+	}
+
+	perf := result.Performance
+	if perf != nil {
+		build.WriteString(reportMetrics(perf))
+		build.WriteString(reportStatusCodes(perf))
+	}
+	build.WriteString(reportErrors(history))
+	build.WriteString(NewTimeSeries(history, timeSeriesInterval).HTML())
+
+	if visualization != "" {
+		build.WriteString("<h2>Linearization</h2>\n")
+		build.WriteString(fmt.Sprintf("<iframe srcdoc=\"%s\"></iframe>\n", html.EscapeString(visualization)))
+	}
+
+	build.WriteString("</body>\n</html>\n")
+	return build.String()
+}
+
+func reportVerdict(result *Result) string {
+	build := strings.Builder{}
+	pass := result.Err() == nil
+	build.WriteString(fmt.Sprintf("<h1>Durable Promise Test Harness: <span class=\"%s\">%s</span></h1>\n", strings.ToLower(verdict(pass)), verdict(pass)))
+	build.WriteString("<table>\n")
+	build.WriteString(fmt.Sprintf("<tr><td>Linearizability Check</td><td>%s</td></tr>\n", linearizability(result)))
+	if result.Graph != nil {
+		build.WriteString(fmt.Sprintf("<tr><td>Dependency Graph Check</td><td>%s</td></tr>\n", verdict(result.Graph.Pass)))
+	}
+	for _, c := range result.Consistency {
+		v := verdict(c.Pass)
+		if c.Inconclusive {
+			v = "UNKNOWN"
+		}
+		build.WriteString(fmt.Sprintf("<tr><td>%s Check</td><td>%s</td></tr>\n", c.Model.String(), v))
+	}
+	if result.Clock != nil {
+		build.WriteString(fmt.Sprintf("<tr><td>Server Clock Offset</td><td>%s</td></tr>\n", html.EscapeString(result.Clock.String())))
+		if result.Clock.Drift {
+			build.WriteString(fmt.Sprintf("<tr><td>Timing Checks</td><td>%s</td></tr>\n", driftWarning))
+		}
+	}
+	if len(result.Thresholds) > 0 {
+		build.WriteString(fmt.Sprintf("<tr><td>Performance Thresholds</td><td>%s</td></tr>\n", verdict(result.ThresholdsPass)))
+		for _, t := range result.Thresholds {
+			build.WriteString(fmt.Sprintf("<tr><td>&nbsp;&nbsp;%s</td><td>%s</td></tr>\n", html.EscapeString(t.Message), verdict(t.Pass)))
+		}
+	}
+	build.WriteString("</table>\n")
+	return build.String()
+}
+
+func reportRounds(rounds []RoundResult) string {
+	if len(rounds) == 0 {
+		return ""
+	}
+
+	build := strings.Builder{}
+	build.WriteString("<h2>Rounds</h2>\n<table>\n<tr><th>Round</th><th>Operations</th><th>Verdict</th><th>Failed</th></tr>\n")
+	for _, r := range rounds {
+		build.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%d</td><td>%s</td><td>%s</td></tr>\n",
+			r.Round, r.Operations, verdict(r.Pass), html.EscapeString(strings.Join(r.Failed, ", "))))
+	}
+	build.WriteString("</table>\n")
+	return build.String()
+}
+
+func reportAnomalies(g *GraphResult) string {
+	if len(g.Anomalies) == 0 {
+		return ""
+	}
+
+	build := strings.Builder{}
+	build.WriteString(fmt.Sprintf("<h2>Anomalies</h2>\n<p>%d operations, %d edges</p>\n<table>\n", g.Operations, g.Edges))
+	build.WriteString("<tr><th>Kind</th><th>Promise</th><th>Description</th><th>Operations</th></tr>\n")
+	for _, a := range g.Anomalies {
+		ops := make([]string, len(a.Operations))
+		for i, op := range a.Operations {
+			ops[i] = html.EscapeString(op.String())
+			if i < len(a.Edges) {
+				ops[i] += fmt.Sprintf(" &ndash;%s&rarr;", a.Edges[i])
+			}
+		}
+		build.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			a.Kind, html.EscapeString(a.Promise), html.EscapeString(a.Description), strings.Join(ops, "<br>")))
+	}
+	build.WriteString("</table>\n")
+	return build.String()
+}
+
+func reportConsistency(c ConsistencyResult) string {
+	if len(c.Violations) == 0 {
+		return ""
+	}
+
+	build := strings.Builder{}
+	build.WriteString(fmt.Sprintf("<h2>%s Violations</h2>\n<table>\n", c.Model.String()))
+	build.WriteString("<tr><th>Operation</th><th>Id</th><th>Client</th><th>Trace</th><th>Reason</th></tr>\n")
+	for _, v := range c.Violations {
+		build.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%d</td><td>%s</td><td>%s</td></tr>\n",
+			html.EscapeString(v.Description), v.OperationID, v.ClientID, html.EscapeString(v.TraceID), html.EscapeString(v.Reason)))
+	}
+	build.WriteString("</table>\n")
+	return build.String()
+}
+
+func reportConfig(config map[string]string) string {
+	if len(config) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(config))
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	build := strings.Builder{}
+	build.WriteString("<h2>Config</h2>\n<table>\n")
+	for _, k := range keys {
+		build.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td></tr>\n", html.EscapeString(k), html.EscapeString(config[k])))
+	}
+	build.WriteString("</table>\n")
+	return build.String()
+}
+
+func reportMetrics(perf *Performance) string {
+	build := strings.Builder{}
+	build.WriteString(fmt.Sprintf("<h2>Performance (%s)</h2>\n<table>\n", fmtDuration(perf.Duration)))
+	build.WriteString("<tr><th>API</th><th>Count</th><th>OK</th><th>Fail</th><th>Errors</th><th>RPS</th><th>Min</th><th>Mean</th>")
+	for _, p := range percentiles {
+		build.WriteString(fmt.Sprintf("<th>%s</th>", p.name))
+	}
+	build.WriteString("<th>Max</th></tr>\n")
+
+	rows := make([]Metrics, 0, len(perf.APIs)+1)
+	rows = append(rows, perf.APIs...)
+	rows = append(rows, perf.Overall)
+	for _, m := range rows {
+		build.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%d</td><td>%d</td><td>%d</td><td>%.2f</td><td>%s</td><td>%s</td>",
+			html.EscapeString(m.API), m.Count, m.Ok, m.Fail, m.Errors, m.RPS, fmtDuration(m.Min), fmtDuration(m.Mean)))
+		for _, p := range percentiles {
+			build.WriteString(fmt.Sprintf("<td>%s</td>", fmtDuration(m.Percentiles[p.name])))
+		}
+		build.WriteString(fmt.Sprintf("<td>%s</td></tr>\n", fmtDuration(m.Max)))
+	}
+	build.WriteString("</table>\n")
+	return build.String()
+}
+
+func reportStatusCodes(perf *Performance) string {
+	codes := map[int]bool{}
+	for _, m := range perf.APIs {
+		for code := range m.StatusCodes {
+			codes[code] = true
+		}
+	}
+	sorted := make([]int, 0, len(codes))
+	for code := range codes {
+		sorted = append(sorted, code)
+	}
+	sort.Ints(sorted)
+
+	build := strings.Builder{}
+	build.WriteString("<h2>Status Codes</h2>\n<table>\n<tr><th>API</th>")
+	for _, code := range sorted {
+		build.WriteString(fmt.Sprintf("<th>%d</th>", code))
+	}
+	build.WriteString("</tr>\n")
+	for _, m := range perf.APIs {
+		build.WriteString(fmt.Sprintf("<tr><td>%s</td>", html.EscapeString(m.API)))
+		for _, code := range sorted {
+			build.WriteString(fmt.Sprintf("<td>%d</td>", m.StatusCodes[code]))
+		}
+		build.WriteString("</tr>\n")
+	}
+	build.WriteString("</table>\n")
+	return build.String()
+}
+
+func reportErrors(history []store.Operation) string {
+	errs := calculateErrorDistribution(history)
+	if len(errs) == 0 {
+		return ""
+	}
+
+	build := strings.Builder{}
+	build.WriteString("<h2>Error Distribution</h2>\n<table>\n<tr><th>API</th><th>Code</th><th>Message</th><th>Responses</th></tr>\n")
+	for _, e := range errs {
+		build.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%s</td><td>%d</td></tr>\n", e.api, e.code, html.EscapeString(e.message), e.count))
+	}
+	build.WriteString("</table>\n")
+	return build.String()
+}
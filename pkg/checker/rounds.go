@@ -0,0 +1,104 @@
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+// RoundResult is the verdict of one round of a run with several rounds.
+// Rounds never share promises, so each is checked on its own.
+type RoundResult struct {
+	Round        int                 `json:"round"`
+	Operations   int                 `json:"operations"`
+	Pass         bool                `json:"pass"`
+	Failed       []string            `json:"failed,omitempty"`
+	Linearizable bool                `json:"linearizable"`
+	Explanation  *Explanation        `json:"explanation,omitempty"`
+	Graph        *GraphResult        `json:"graph,omitempty"`
+	Consistency  []ConsistencyResult `json:"consistency,omitempty"`
+}
+
+// judge sets the verdict of the round from the checks the run requires.
+func (r *RoundResult) judge(result *Result) {
+	if result.uses(Porcupine) && !r.Linearizable && result.requires(Linearizable) {
+		r.Failed = append(r.Failed, "linearizability")
+	}
+	if r.Graph != nil && !r.Graph.Pass && result.requires(Linearizable) {
+		r.Failed = append(r.Failed, fmt.Sprintf("%d anomalies", len(r.Graph.Anomalies)))
+	}
+	for _, c := range r.Consistency {
+		if !c.Pass && result.requires(c.Model) {
+			r.Failed = append(r.Failed, strings.ToLower(c.Model.String()))
+		}
+	}
+	r.Pass = len(r.Failed) == 0
+}
+
+func (r RoundResult) String() string {
+	s := fmt.Sprintf("  Round %d: %s (%d operations)", r.Round, verdict(r.Pass), r.Operations)
+	if len(r.Failed) > 0 {
+		s += ", failed " + strings.Join(r.Failed, ", ")
+	}
+	return s + "\n"
+}
+
+type round struct {
+	number int
+	ops    []store.Operation
+}
+
+// splitRounds groups the history by round in round order. A history without
+// rounds is a single round.
+func splitRounds(history []store.Operation) []round {
+	byRound := map[int][]store.Operation{}
+	for _, op := range history {
+		byRound[op.Round] = append(byRound[op.Round], op)
+	}
+	if len(byRound) == 0 {
+		return []round{{ops: history}}
+	}
+
+	rounds := make([]round, 0, len(byRound))
+	for n, ops := range byRound {
+		rounds = append(rounds, round{number: n, ops: ops})
+	}
+	sort.Slice(rounds, func(i, j int) bool {
+		return rounds[i].number < rounds[j].number
+	})
+	return rounds
+}
+
+// add folds the verdict of a round into the verdict of the run.
+func (r *Result) add(round *RoundResult, first bool) {
+	if first {
+		r.Linearizable = round.Linearizable
+	} else {
+		r.Linearizable = r.Linearizable && round.Linearizable
+	}
+	if r.Explanation == nil && round.Explanation != nil {
+		r.Explanation = round.Explanation
+	}
+
+	if round.Graph != nil {
+		if r.Graph == nil {
+			r.Graph = &GraphResult{Pass: true}
+		}
+		r.Graph.Pass = r.Graph.Pass && round.Graph.Pass
+		r.Graph.Operations += round.Graph.Operations
+		r.Graph.Edges += round.Graph.Edges
+		r.Graph.Anomalies = append(r.Graph.Anomalies, round.Graph.Anomalies...)
+	}
+
+	for i, c := range round.Consistency {
+		if first {
+			r.Consistency = append(r.Consistency, ConsistencyResult{Model: c.Model, Pass: true})
+		}
+		merged := &r.Consistency[i]
+		merged.Pass = merged.Pass && c.Pass
+		merged.Inconclusive = merged.Inconclusive || c.Inconclusive
+		merged.Violations = append(merged.Violations, c.Violations...)
+	}
+}
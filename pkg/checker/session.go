@@ -0,0 +1,224 @@
+package checker
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+// Session guarantees are checked per client, every client being a session
+// that issues one operation at a time.
+
+// version orders what can be observed of a promise over its lifetime.
+type version int
+
+const (
+	absent version = iota
+	pending
+	completed
+)
+
+func (v version) String() string {
+	switch v {
+	case absent:
+		return "absent"
+	case pending:
+		return "pending"
+	default:
+		return "completed"
+	}
+}
+
+// observation is what an operation saw or left behind of a single promise.
+type observation struct {
+	version version
+	// state is set for completed promises when the exact state is known.
+	state openapi.PromiseState
+}
+
+func (o observation) String() string {
+	if o.state != "" {
+		return string(o.state)
+	}
+	return o.version.String()
+}
+
+// before reports whether o is older than other, or conflicts with it.
+func (o observation) before(other observation) bool {
+	if o.version != other.version {
+		return o.version < other.version
+	}
+	return o.version == completed && o.state != "" && other.state != "" && o.state != other.state
+}
+
+func observe(state openapi.PromiseState) observation {
+	if state == openapi.PromiseStatePENDING {
+		return observation{version: pending}
+	}
+	return observation{version: completed, state: state}
+}
+
+// clientOrder returns the history ordered by client and then by call time.
+func clientOrder(history []store.Operation) []store.Operation {
+	ops := append([]store.Operation{}, history...)
+	sort.SliceStable(ops, func(i, j int) bool {
+		if ops[i].ClientID != ops[j].ClientID {
+			return ops[i].ClientID < ops[j].ClientID
+		}
+		return ops[i].CallEvent.Before(ops[j].CallEvent)
+	})
+	return ops
+}
+
+// reads returns the promises observed by a read operation.
+func reads(op store.Operation) map[string]observation {
+	obs := map[string]observation{}
+	switch op.API {
+	case store.Get:
+		id, _ := op.Input.(string)
+		if op.Status == store.Ok {
+			if p, ok := op.Output.(*openapi.Promise); ok && p != nil {
+				obs[id] = observe(p.State)
+			}
+		} else if op.Status == store.Fail && op.Code == http.StatusNotFound {
+			obs[id] = observation{version: absent}
+		}
+	case store.Search:
+		// a search only tells which promises exist, not which do not
+		if op.Status == store.Ok {
+			if resp, ok := op.Output.(*openapi.SearchPromisesResponseObj); ok && resp != nil && resp.Promises != nil {
+				for _, p := range *resp.Promises {
+					obs[p.Id] = observe(p.State)
+				}
+			}
+		}
+	}
+	return obs
+}
+
+// written returns the promise a successful write left behind.
+func written(op store.Operation) (string, observation, bool) {
+	if op.Status != store.Ok {
+		return "", observation{}, false
+	}
+	p, ok := op.Output.(*openapi.Promise)
+	if !ok || p == nil {
+		return "", observation{}, false
+	}
+	switch op.API {
+	case store.Create, store.Cancel, store.Resolve, store.Reject:
+		return p.Id, observe(p.State), true
+	default:
+		return "", observation{}, false
+	}
+}
+
+// targets returns the promise a write operation targets.
+func targets(op store.Operation) (string, bool) {
+	switch v := op.Input.(type) {
+	case *openapi.CreatePromiseJSONRequestBody:
+		if v == nil {
+			return "", false
+		}
+		return v.Id, true
+	case *openapi.CompletePromiseRequestWrapper:
+		if v == nil || v.Id == nil {
+			return "", false
+		}
+		return *v.Id, true
+	default:
+		return "", false
+	}
+}
+
+func checkSession(model ConsistencyModel, history []store.Operation) ConsistencyResult {
+	result := ConsistencyResult{Model: model, Pass: true}
+
+	var check func(op store.Operation, writes, seen map[string]observation) []string
+	switch model {
+	case ReadYourWrites:
+		check = checkReadYourWrites
+	case MonotonicReads:
+		check = checkMonotonicReads
+	case MonotonicWrites:
+		check = checkMonotonicWrites
+	default:
+		panic(fmt.Sprintf("unknown session guarantee: %s", model))
+	}
+
+	var client int
+	var writes, seen map[string]observation
+	for i, op := range clientOrder(history) {
+		if i == 0 || op.ClientID != client {
+			client = op.ClientID
+			writes, seen = map[string]observation{}, map[string]observation{}
+		}
+
+		for _, reason := range check(op, writes, seen) {
+			result.Violations = append(result.Violations, newViolation(op, reason))
+		}
+
+		for id, o := range reads(op) {
+			if last, ok := seen[id]; !ok || last.before(o) {
+				seen[id] = o
+			}
+		}
+		if id, o, ok := written(op); ok {
+			writes[id] = o
+		}
+	}
+
+	result.Pass = len(result.Violations) == 0
+	return result
+}
+
+// checkReadYourWrites verifies reads reflect the earlier writes of the same client.
+func checkReadYourWrites(op store.Operation, writes, _ map[string]observation) []string {
+	reasons := []string{}
+	for id, o := range reads(op) {
+		if w, ok := writes[id]; ok && o.before(w) {
+			reasons = append(reasons, fmt.Sprintf("read %s of promise '%s' after writing %s", o, id, w))
+		}
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// checkMonotonicReads verifies reads never go back to an older version than
+// an earlier read of the same client.
+func checkMonotonicReads(op store.Operation, _, seen map[string]observation) []string {
+	reasons := []string{}
+	for id, o := range reads(op) {
+		if last, ok := seen[id]; ok && o.before(last) {
+			reasons = append(reasons, fmt.Sprintf("read %s of promise '%s' after reading %s", o, id, last))
+		}
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// checkMonotonicWrites verifies writes are applied after the earlier writes of the same client.
+func checkMonotonicWrites(op store.Operation, writes, _ map[string]observation) []string {
+	id, ok := targets(op)
+	if !ok {
+		return nil
+	}
+	w, ok := writes[id]
+	if !ok {
+		return nil
+	}
+
+	switch {
+	case op.API == store.Create && op.Status == store.Ok && op.Code == http.StatusCreated:
+		return []string{fmt.Sprintf("created promise '%s' again after writing %s", id, w)}
+	case op.API != store.Create && op.Status == store.Fail && op.Code == http.StatusNotFound:
+		return []string{fmt.Sprintf("promise '%s' not found after writing %s", id, w)}
+	}
+	if _, o, ok := written(op); ok && o.before(w) {
+		return []string{fmt.Sprintf("wrote %s of promise '%s' after writing %s", o, id, w)}
+	}
+	return nil
+}
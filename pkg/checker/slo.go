@@ -0,0 +1,237 @@
+package checker
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+type metricKind int
+
+const (
+	latencyMetric metricKind = iota
+	rateMetric
+	throughputMetric
+)
+
+// sloMetrics are the metrics a threshold can be declared against.
+var sloMetrics = map[string]metricKind{
+	"min":        latencyMetric,
+	"mean":       latencyMetric,
+	"max":        latencyMetric,
+	"p50":        latencyMetric,
+	"p75":        latencyMetric,
+	"p90":        latencyMetric,
+	"p95":        latencyMetric,
+	"p99":        latencyMetric,
+	"p99.9":      latencyMetric,
+	"error_rate": rateMetric,
+	"fail_rate":  rateMetric,
+	"rps":        throughputMetric,
+}
+
+// operators are ordered so that two character operators match first.
+var operators = []string{"<=", ">=", "<", ">"}
+
+// Threshold is a declarative performance objective such as "GET.p99<50ms",
+// "error_rate<0.1%" or "rps>=500". Thresholds without an api prefix apply to
+// the run as a whole.
+type Threshold struct {
+	Raw    string  `json:"raw"`
+	API    string  `json:"api,omitempty"`
+	Metric string  `json:"metric"`
+	Op     string  `json:"op"`
+	Value  float64 `json:"value"`
+}
+
+// ThresholdResult is the outcome of evaluating a threshold against a run.
+type ThresholdResult struct {
+	Threshold Threshold `json:"threshold"`
+	Actual    float64   `json:"actual"`
+	Pass      bool      `json:"pass"`
+	Message   string    `json:"message"`
+}
+
+// ParseThreshold parses an expression of the form [API.]metric op value.
+func ParseThreshold(expr string) (Threshold, error) {
+	raw := strings.TrimSpace(expr)
+	s := strings.ReplaceAll(raw, " ", "")
+
+	var op string
+	var idx int
+	for _, o := range operators {
+		if i := strings.Index(s, o); i > 0 {
+			op, idx = o, i
+			break
+		}
+	}
+	if op == "" {
+		return Threshold{}, fmt.Errorf("threshold '%s' is missing an operator, expected one of %v", raw, operators)
+	}
+
+	t := Threshold{Raw: raw, Op: op}
+	lhs, rhs := s[:idx], s[idx+len(op):]
+
+	t.Metric = strings.ToLower(lhs)
+	for metric := range sloMetrics {
+		if strings.HasSuffix(t.Metric, "."+metric) {
+			t.API = strings.ToUpper(lhs[:len(lhs)-len(metric)-1])
+			t.Metric = metric
+			break
+		}
+	}
+
+	kind, ok := sloMetrics[t.Metric]
+	if !ok {
+		return Threshold{}, fmt.Errorf("threshold '%s' has unknown metric '%s'", raw, t.Metric)
+	}
+	if _, ok := store.ParseAPI(t.API); t.API != "" && !ok {
+		return Threshold{}, fmt.Errorf("threshold '%s' has unknown api '%s'", raw, t.API)
+	}
+
+	switch kind {
+	case latencyMetric:
+		d, err := time.ParseDuration(rhs)
+		if err != nil {
+			return Threshold{}, fmt.Errorf("threshold '%s' expects a duration: %v", raw, err)
+		}
+		t.Value = float64(d)
+	case rateMetric:
+		percent := strings.HasSuffix(rhs, "%")
+		v, err := strconv.ParseFloat(strings.TrimSuffix(rhs, "%"), 64)
+		if err != nil {
+			return Threshold{}, fmt.Errorf("threshold '%s' expects a rate: %v", raw, err)
+		}
+		if percent {
+			v /= 100
+		}
+		t.Value = v
+	case throughputMetric:
+		v, err := strconv.ParseFloat(rhs, 64)
+		if err != nil {
+			return Threshold{}, fmt.Errorf("threshold '%s' expects a number: %v", raw, err)
+		}
+		t.Value = v
+	}
+
+	return t, nil
+}
+
+// ParseThresholds parses expressions from flags and, if path is set, from a
+// file holding one expression per line. Blank lines and lines starting with
+// '#' are ignored.
+func ParseThresholds(exprs []string, path string) ([]Threshold, error) {
+	all := append([]string{}, exprs...)
+
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			all = append(all, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	thresholds := make([]Threshold, 0, len(all))
+	for _, expr := range all {
+		t, err := ParseThreshold(expr)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, nil
+}
+
+// Evaluate checks the threshold against the metrics of a run.
+func (t Threshold) Evaluate(perf *Performance) ThresholdResult {
+	res := ThresholdResult{Threshold: t}
+
+	m, ok := perf.Metrics(t.API)
+	if !ok {
+		res.Message = fmt.Sprintf("%s: no %s operations were run", t.Raw, t.API)
+		return res
+	}
+
+	switch t.Metric {
+	case "min":
+		res.Actual = float64(m.Min)
+	case "mean":
+		res.Actual = float64(m.Mean)
+	case "max":
+		res.Actual = float64(m.Max)
+	case "error_rate":
+		res.Actual = rate(m.Errors, m.Count)
+	case "fail_rate":
+		res.Actual = rate(m.Fail, m.Count)
+	case "rps":
+		res.Actual = m.RPS
+	default:
+		res.Actual = float64(m.Percentiles[t.Metric])
+	}
+
+	switch t.Op {
+	case "<":
+		res.Pass = res.Actual < t.Value
+	case "<=":
+		res.Pass = res.Actual <= t.Value
+	case ">":
+		res.Pass = res.Actual > t.Value
+	case ">=":
+		res.Pass = res.Actual >= t.Value
+	}
+
+	verdict := "PASS"
+	if !res.Pass {
+		verdict = "FAIL"
+	}
+	res.Message = fmt.Sprintf("%s: %s (actual %s)", t.Raw, verdict, t.format(res.Actual))
+
+	return res
+}
+
+func (t Threshold) format(v float64) string {
+	switch sloMetrics[t.Metric] {
+	case latencyMetric:
+		return time.Duration(v).Round(time.Microsecond).String()
+	case rateMetric:
+		return fmt.Sprintf("%.4f%%", v*100)
+	default:
+		return fmt.Sprintf("%.2f", v)
+	}
+}
+
+// EvaluateThresholds evaluates every threshold and reports whether all of them passed.
+func EvaluateThresholds(thresholds []Threshold, perf *Performance) ([]ThresholdResult, bool) {
+	pass := true
+	results := make([]ThresholdResult, 0, len(thresholds))
+	for _, t := range thresholds {
+		res := t.Evaluate(perf)
+		pass = pass && res.Pass
+		results = append(results, res)
+	}
+	return results, pass
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
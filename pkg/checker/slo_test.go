@@ -0,0 +1,48 @@
+package checker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		expr string
+		want Threshold
+		err  bool
+	}{
+		{expr: "GET.p99<50ms", want: Threshold{API: "GET", Metric: "p99", Op: "<", Value: float64(50 * time.Millisecond)}},
+		{expr: "error_rate<0.1%", want: Threshold{Metric: "error_rate", Op: "<", Value: 0.1 / 100}},
+		{expr: "rps>=500", want: Threshold{Metric: "rps", Op: ">=", Value: 500}},
+		{expr: "get.p99.9 <= 1s", want: Threshold{API: "GET", Metric: "p99.9", Op: "<=", Value: float64(time.Second)}},
+		{expr: "create.mean>2ms", want: Threshold{API: "CREATE", Metric: "mean", Op: ">", Value: float64(2 * time.Millisecond)}},
+		{expr: "fail_rate<=0.05", want: Threshold{Metric: "fail_rate", Op: "<=", Value: 0.05}},
+		{expr: "p99 50ms", err: true},
+		{expr: "<50ms", err: true},
+		{expr: "p42<50ms", err: true},
+		{expr: "BOGUS.p99<50ms", err: true},
+		{expr: "p99<fast", err: true},
+		{expr: "p99<50", err: true},
+		{expr: "error_rate<x%", err: true},
+		{expr: "rps>=lots", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			got, err := ParseThreshold(test.expr)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.want.Raw = test.expr
+			if got != test.want {
+				t.Fatalf("expected %+v, got %+v", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package checker
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+// timeSeriesInterval is the width of every bucket in the time series.
+const timeSeriesInterval = time.Second
+
+// Bucket holds the metrics of all operations that returned within one interval of the run.
+type Bucket struct {
+	Offset    time.Duration
+	Count     int
+	Fail      int
+	Errors    int
+	RPS       float64
+	FailRate  float64
+	ErrorRate float64
+	P50       time.Duration
+	P90       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+}
+
+// TimeSeries splits a run into fixed intervals so warmup effects, pauses
+// and degradation over long runs become visible.
+type TimeSeries struct {
+	Start    time.Time
+	Interval time.Duration
+	Buckets  []Bucket
+}
+
+func NewTimeSeries(history []store.Operation, interval time.Duration) *TimeSeries {
+	ts := &TimeSeries{Interval: interval}
+	if len(history) == 0 {
+		return ts
+	}
+
+	start, end := history[0].CallEvent, history[0].ReturnEvent
+	for i := range history {
+		if history[i].CallEvent.Before(start) {
+			start = history[i].CallEvent
+		}
+		if history[i].ReturnEvent.After(end) {
+			end = history[i].ReturnEvent
+		}
+	}
+	ts.Start = start
+
+	n := int(end.Sub(start)/interval) + 1
+	hists := make([]*Histogram, n)
+	ts.Buckets = make([]Bucket, n)
+	for i := range ts.Buckets {
+		hists[i] = NewHistogram()
+		ts.Buckets[i].Offset = time.Duration(i) * interval
+	}
+
+	for i := range history {
+		op := history[i]
+		idx := int(op.ReturnEvent.Sub(start) / interval)
+		b := &ts.Buckets[idx]
+		b.Count++
+		if op.Status != store.Ok {
+			b.Fail++
+		}
+		if isError(op) {
+			b.Errors++
+		}
+		hists[idx].Record(op.ReturnEvent.Sub(op.CallEvent))
+	}
+
+	for i := range ts.Buckets {
+		b := &ts.Buckets[i]
+		// the last bucket is usually partial, so use its actual width
+		width := interval
+		if rest := end.Sub(start.Add(b.Offset)); rest < width {
+			width = rest
+		}
+		if width > 0 {
+			b.RPS = float64(b.Count) / width.Seconds()
+		}
+		if b.Count > 0 {
+			b.FailRate = float64(b.Fail) / float64(b.Count)
+			b.ErrorRate = float64(b.Errors) / float64(b.Count)
+		}
+		b.P50 = hists[i].Percentile(0.50)
+		b.P90 = hists[i].Percentile(0.90)
+		b.P99 = hists[i].Percentile(0.99)
+		b.Max = hists[i].Max()
+	}
+
+	return ts
+}
+
+// isError reports whether an operation failed for reasons other than the
+// expected api semantics, that is no response at all or a server error.
+func isError(op store.Operation) bool {
+	return op.Status == store.Invoke || op.Code >= 500
+}
+
+// CSV renders one row per bucket.
+func (ts *TimeSeries) CSV() string {
+	build := strings.Builder{}
+	build.WriteString("offset_s,count,rps,fail,fail_rate,errors,error_rate,p50_ms,p90_ms,p99_ms,max_ms\n")
+	for _, b := range ts.Buckets {
+		build.WriteString(fmt.Sprintf(
+			"%g,%d,%.2f,%d,%.4f,%d,%.4f,%.3f,%.3f,%.3f,%.3f\n",
+			b.Offset.Seconds(),
+			b.Count,
+			b.RPS,
+			b.Fail,
+			b.FailRate,
+			b.Errors,
+			b.ErrorRate,
+			toMillis(b.P50),
+			toMillis(b.P90),
+			toMillis(b.P99),
+			toMillis(b.Max),
+		))
+	}
+	return build.String()
+}
+
+// HTML renders the time series as a set of inline svg charts.
+func (ts *TimeSeries) HTML() string {
+	xs := make([]float64, len(ts.Buckets))
+	rps := make([]float64, len(ts.Buckets))
+	failRate := make([]float64, len(ts.Buckets))
+	errorRate := make([]float64, len(ts.Buckets))
+	p50 := make([]float64, len(ts.Buckets))
+	p90 := make([]float64, len(ts.Buckets))
+	p99 := make([]float64, len(ts.Buckets))
+	for i, b := range ts.Buckets {
+		xs[i] = b.Offset.Seconds()
+		rps[i] = b.RPS
+		failRate[i] = b.FailRate * 100
+		errorRate[i] = b.ErrorRate * 100
+		p50[i] = toMillis(b.P50)
+		p90[i] = toMillis(b.P90)
+		p99[i] = toMillis(b.P99)
+	}
+
+	build := strings.Builder{}
+	build.WriteString(`<section class="timeseries">` + "\n")
+	build.WriteString(fmt.Sprintf("<h2>Time Series (%v buckets)</h2>\n", ts.Interval))
+	build.WriteString(lineChart("Throughput", "req/s", xs, []series{{"rps", rps}}))
+	build.WriteString(lineChart("Failure Rate", "%", xs, []series{{"non 2xx", failRate}, {"errors", errorRate}}))
+	build.WriteString(lineChart("Latency", "ms", xs, []series{{"p50", p50}, {"p90", p90}, {"p99", p99}}))
+	build.WriteString("</section>\n")
+	return build.String()
+}
+
+func toMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+//
+// svg charts
+//
+
+type series struct {
+	name   string
+	values []float64
+}
+
+var seriesColors = []string{"#1f77b4", "#d62728", "#2ca02c", "#ff7f0e"}
+
+const (
+	chartWidth   = 720
+	chartHeight  = 220
+	chartPadding = 40
+)
+
+// lineChart renders a self contained svg line chart, no scripts or external assets required.
+func lineChart(title, unit string, xs []float64, ss []series) string {
+	maxX, maxY := 0.0, 0.0
+	for _, x := range xs {
+		maxX = math.Max(maxX, x)
+	}
+	for _, s := range ss {
+		for _, y := range s.values {
+			maxY = math.Max(maxY, y)
+		}
+	}
+	if maxX == 0 {
+		maxX = 1
+	}
+	if maxY == 0 {
+		maxY = 1
+	}
+
+	plotW := float64(chartWidth - 2*chartPadding)
+	plotH := float64(chartHeight - 2*chartPadding)
+	px := func(x float64) float64 { return chartPadding + x/maxX*plotW }
+	py := func(y float64) float64 { return chartHeight - chartPadding - y/maxY*plotH }
+
+	build := strings.Builder{}
+	build.WriteString(fmt.Sprintf(`<figure><figcaption>%s</figcaption>`+"\n", html.EscapeString(title)))
+	build.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="11">`+"\n", chartWidth, chartHeight))
+
+	// axes
+	build.WriteString(fmt.Sprintf(`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#999"/>`+"\n", chartPadding, chartHeight-chartPadding, chartWidth-chartPadding, chartHeight-chartPadding))
+	build.WriteString(fmt.Sprintf(`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#999"/>`+"\n", chartPadding, chartPadding, chartPadding, chartHeight-chartPadding))
+	build.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="end">%.2f %s</text>`+"\n", chartPadding-4, chartPadding+4, maxY, html.EscapeString(unit)))
+	build.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="end">0</text>`+"\n", chartPadding-4, chartHeight-chartPadding))
+	build.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="end">%gs</text>`+"\n", chartWidth-chartPadding, chartHeight-chartPadding+16, maxX))
+
+	for i, s := range ss {
+		color := seriesColors[i%len(seriesColors)]
+		points := make([]string, 0, len(s.values))
+		for j, y := range s.values {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", px(xs[j]), py(y)))
+		}
+		build.WriteString(fmt.Sprintf(`<polyline fill="none" stroke="%s" stroke-width="1.5" points="%s"/>`+"\n", color, strings.Join(points, " ")))
+		if len(s.values) <= 120 {
+			// markers keep short runs, down to a single bucket, readable
+			for j, y := range s.values {
+				build.WriteString(fmt.Sprintf(`<circle cx="%.1f" cy="%.1f" r="2" fill="%s"/>`+"\n", px(xs[j]), py(y), color))
+			}
+		}
+		build.WriteString(fmt.Sprintf(`<text x="%d" y="%d" fill="%s">%s</text>`+"\n", chartPadding+10+i*70, chartPadding-10, color, html.EscapeString(s.name)))
+	}
+
+	build.WriteString("</svg></figure>\n")
+	return build.String()
+}
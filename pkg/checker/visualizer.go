@@ -3,13 +3,14 @@ package checker
 import (
 	"encoding/json"
 	"fmt"
-	"math"
+	"html"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/resonatehq/durable-promise-test-harness/pkg/artifacts"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
 	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
-	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
 )
 
 type Visualizer struct{}
@@ -19,13 +20,46 @@ func NewVisualizer() *Visualizer {
 }
 
 // renders timeline of history and performance analysis
-func (v *Visualizer) Summary(pass bool, today string, history []store.Operation) error {
-	summary := v.summary(pass)
-	performance := v.performance(history)
+func (v *Visualizer) Summary(result *Result, run *artifacts.Run, history []store.Operation) error {
+	perf := result.Performance
+
+	summary := v.summary(result)
+	if result.Explanation != nil {
+		summary += "\n" + result.Explanation.String()
+	}
+	performance := v.performance(perf, history)
 	timeline := v.timeline(history)
 
 	content := summary + "\n" + performance + "\n" + timeline
-	err := utils.WriteStringToFile(content, fmt.Sprintf("test/results/%s/summary.txt", today))
+	err := run.WriteString("summary.txt", "verdict, performance analysis and event history", content)
+	if err != nil {
+		return err
+	}
+
+	perfJSON, err := perf.JSON()
+	if err != nil {
+		return err
+	}
+	err = run.WriteString("performance.json", "per api latency, throughput and status codes", perfJSON)
+	if err != nil {
+		return err
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = run.WriteString("result.json", "verdict and metrics read by harness compare", string(resultJSON))
+	if err != nil {
+		return err
+	}
+
+	ts := NewTimeSeries(history, timeSeriesInterval)
+	err = run.WriteString("timeseries.csv", "throughput, failure rate and latency per second", ts.CSV())
+	if err != nil {
+		return err
+	}
+	err = run.WriteString("timeseries.html", "charts of throughput, failure rate and latency per second", htmlPage("Time Series", ts.HTML()))
 	if err != nil {
 		return err
 	}
@@ -35,18 +69,64 @@ func (v *Visualizer) Summary(pass bool, today string, history []store.Operation)
 	return nil
 }
 
-func (v *Visualizer) summary(pass bool) string {
-	out := "PASS"
-	if !pass {
-		out = "FAIL"
-	}
+// htmlPage wraps body in a minimal standalone html document.
+func htmlPage(title, body string) string {
+	build := strings.Builder{}
+	build.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	build.WriteString(fmt.Sprintf("<title>%s</title>\n", html.EscapeString(title)))
+	build.WriteString("<style>body{font-family:sans-serif;margin:2em}figure{margin:1em 0}figcaption{font-weight:bold}</style>\n")
+	build.WriteString("</head>\n<body>\n")
+	build.WriteString(body)
+	build.WriteString("</body>\n</html>\n")
+	return build.String()
+}
+
+func (v *Visualizer) summary(result *Result) string {
 	build := strings.Builder{}
 	build.WriteString("Summary\n")
 	build.WriteString("=====================\n")
-	build.WriteString(fmt.Sprintf("Linearizability Check: %s\n", out))
+	build.WriteString(fmt.Sprintf("Linearizability Check: %s\n", linearizability(result)))
+	if result.Graph != nil {
+		build.WriteString(result.Graph.String())
+	}
+	for _, c := range result.Consistency {
+		build.WriteString(c.String())
+	}
+	if len(result.Rounds) > 0 {
+		build.WriteString("Rounds:\n")
+		for _, r := range result.Rounds {
+			build.WriteString(r.String())
+		}
+	}
+	if result.Clock != nil {
+		build.WriteString(fmt.Sprintf("Server Clock Offset: %s\n", result.Clock))
+		if result.Clock.Drift {
+			build.WriteString(fmt.Sprintf("Timing Checks: %s\n", driftWarning))
+		}
+	}
+	if len(result.Thresholds) > 0 {
+		build.WriteString(fmt.Sprintf("Performance Thresholds: %s\n", verdict(result.ThresholdsPass)))
+		for _, t := range result.Thresholds {
+			build.WriteString(fmt.Sprintf("  %s\n", t.Message))
+		}
+	}
 	return build.String()
 }
 
+func linearizability(result *Result) string {
+	if !result.uses(Porcupine) {
+		return "SKIPPED"
+	}
+	return verdict(result.Linearizable)
+}
+
+func verdict(pass bool) string {
+	if pass {
+		return "PASS"
+	}
+	return "FAIL"
+}
+
 func (v *Visualizer) timeline(history []store.Operation) string {
 	events := makeEvents(history)
 
@@ -59,129 +139,68 @@ func (v *Visualizer) timeline(history []store.Operation) string {
 	return build.String()
 }
 
-func (v *Visualizer) performance(history []store.Operation) string {
-	reqTimes := []time.Duration{}
-	for i := range history {
-		latency := history[i].ReturnEvent.Sub(history[i].CallEvent)
-		reqTimes = append(reqTimes, latency)
-	}
-
+func (v *Visualizer) performance(perf *Performance, history []store.Operation) string {
 	build := strings.Builder{}
 
-	// Requests
-	build.WriteString("Requests:\n")
-	build.WriteString(fmt.Sprintf("  Total: %v\n", cumulative(history)))
-	build.WriteString(fmt.Sprintf("  Slowest: %v\n", slowest(reqTimes)))
-	build.WriteString(fmt.Sprintf("  Fastest: %v\n", fastest(reqTimes)))
-	build.WriteString(fmt.Sprintf("  Average: %v\n", average(reqTimes)))
-	build.WriteString(fmt.Sprintf("  Requests/Sec: %.2f\n", calculateThroughputRPS(history)))
+	// Requests, latency and status codes per api
+	build.WriteString(perf.String())
 	build.WriteString("\n")
 
 	// Data
+	seconds := time.Duration(perf.Duration).Seconds()
 	build.WriteString("Data:\n")
-	build.WriteString(fmt.Sprintf("  Total Data: %.4f MB\n", totalDataSize(history)))
-	build.WriteString(fmt.Sprintf("  Size/Sec: %.4f MB\n", dataSizePerSecond(history)))
-	build.WriteString("\n")
-
-	// Latency
-	build.WriteString("Latency Distribution:\n")
-	build.WriteString(fmt.Sprintf("  p50: %v\n", calculateLatencyP(reqTimes, 0.50)))
-	build.WriteString(fmt.Sprintf("  p75: %v\n", calculateLatencyP(reqTimes, 0.75)))
-	build.WriteString(fmt.Sprintf("  p95: %v\n", calculateLatencyP(reqTimes, 0.95)))
-	build.WriteString(fmt.Sprintf("  p99: %v\n", calculateLatencyP(reqTimes, 0.99)))
+	build.WriteString(fmt.Sprintf("  Sent: %.4f MB\n", megabytes(perf.Overall.BytesSent)))
+	build.WriteString(fmt.Sprintf("  Received: %.4f MB\n", megabytes(perf.Overall.BytesReceived)))
+	build.WriteString(fmt.Sprintf("  Total Data: %.4f MB\n", megabytes(perf.Overall.BytesSent+perf.Overall.BytesReceived)))
+	if seconds > 0 {
+		build.WriteString(fmt.Sprintf("  Size/Sec: %.4f MB\n", megabytes(perf.Overall.BytesSent+perf.Overall.BytesReceived)/seconds))
+	}
 	build.WriteString("\n")
 
-	// Status codes
-	build.WriteString("Status Code Distribution:\n")
-	statusCodes := calculateStatusCodeDistribution(history)
-	for code := range statusCodes {
-		build.WriteString(fmt.Sprintf("  %d: %d responses\n", code, statusCodes[code]))
+	// Errors
+	build.WriteString("Error Distribution:\n")
+	for _, e := range calculateErrorDistribution(history) {
+		build.WriteString(fmt.Sprintf("  %s %d %q: %d responses\n", e.api, e.code, e.message, e.count))
 	}
 
 	return build.String()
 }
 
-// per second stuff
-func cumulative(history []store.Operation) time.Duration {
-	sort.Slice(history, func(i, j int) bool {
-		return history[i].CallEvent.Before(history[j].CallEvent)
-	})
-	firstOp, lastOp := history[0], history[len(history)-1]
-	return lastOp.CallEvent.Sub(firstOp.CallEvent)
+func megabytes(n int64) float64 {
+	return float64(n) / (1000 * 1000)
 }
 
-func slowest(latencies []time.Duration) time.Duration {
-	slow := latencies[0]
-	for _, l := range latencies {
-		if slow < l {
-			slow = l
-		}
-	}
-	return slow
+type errorCount struct {
+	api     store.API
+	code    int
+	message string
+	count   int
 }
 
-func fastest(latencies []time.Duration) time.Duration {
-	fast := latencies[0]
-	for _, l := range latencies {
-		if fast > l {
-			fast = l
+// calculateErrorDistribution groups failed operations by api, status code and error message.
+func calculateErrorDistribution(history []store.Operation) []errorCount {
+	counts := map[errorCount]int{}
+	for i := range history {
+		errResp, ok := history[i].Output.(*openapi.ErrorResponse)
+		if !ok || errResp == nil {
+			continue
 		}
+		counts[errorCount{api: history[i].API, code: history[i].Code, message: errResp.Message}]++
 	}
-	return fast
-}
 
-func average(latencies []time.Duration) time.Duration {
-	var total time.Duration
-	for _, l := range latencies {
-		total += l
+	errs := make([]errorCount, 0, len(counts))
+	for k, v := range counts {
+		k.count = v
+		errs = append(errs, k)
 	}
-	return time.Duration(int64(total) / int64(len(latencies)))
-}
-
-func calculateThroughputRPS(history []store.Operation) float64 {
-	sort.Slice(history, func(i, j int) bool {
-		return history[i].CallEvent.Before(history[j].CallEvent)
-	})
-	firstOp, lastOp := history[0], history[len(history)-1]
-	duration := lastOp.CallEvent.Sub(firstOp.CallEvent)
-	return float64(len(history)) / float64(duration.Seconds()) // TODO:
-}
-
-func totalDataSize(history []store.Operation) float64 {
-	var total float64
-	for i := range history {
-		jsonData, _ := json.Marshal(history[i].Input)
-		sizeBytes := len(jsonData)
-		sizeMB := float64(sizeBytes) / (1000 * 1000)
-		total += sizeMB
-	}
-	return total
-}
-
-func dataSizePerSecond(history []store.Operation) float64 {
-	sort.Slice(history, func(i, j int) bool {
-		return history[i].CallEvent.Before(history[j].CallEvent)
-	})
-	firstOp, lastOp := history[0], history[len(history)-1]
-	duration := lastOp.CallEvent.Sub(firstOp.CallEvent)
-	return totalDataSize(history) / float64(duration.Seconds())
-}
-
-func calculateLatencyP(latencies []time.Duration, percentile float64) time.Duration {
-	sort.Slice(latencies, func(i, j int) bool {
-		return latencies[i] < latencies[j]
-	})
-	index := int(math.Ceil(float64(len(latencies)) * percentile))
-	return latencies[index-1]
-}
-
-func calculateStatusCodeDistribution(history []store.Operation) map[int]int {
-	statusCodes := map[int]int{}
-	for i := range history {
-		if history[i].Status == store.Invoke {
-			continue
+	sort.Slice(errs, func(i, j int) bool {
+		if errs[i].api != errs[j].api {
+			return errs[i].api < errs[j].api
 		}
-		statusCodes[history[i].Code]++
-	}
-	return statusCodes
+		if errs[i].code != errs[j].code {
+			return errs[i].code < errs[j].code
+		}
+		return errs[i].message < errs[j].message
+	})
+	return errs
 }
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+const namespace = "harness"
+
+// Metrics publishes live progress of a run in the prometheus exposition format.
+// It is fed by the store as operations start and complete, and by the checker.
+type Metrics struct {
+	registry *prometheus.Registry
+	server   *http.Server
+
+	requests        *prometheus.CounterVec
+	latency         *prometheus.HistogramVec
+	inFlight        *prometheus.GaugeVec
+	checkerRunning  prometheus.Gauge
+	checkerOps      prometheus.Gauge
+	checkerDuration prometheus.Gauge
+	checkerPass     prometheus.Gauge
+	checksTotal     *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Number of completed operations by api, status and http status code.",
+		}, []string{"api", "status", "code"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of completed operations by api.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 18), // 100µs to ~13s
+		}, []string{"api"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "requests_in_flight",
+			Help:      "Number of operations that have been invoked but not yet completed by api.",
+		}, []string{"api"}),
+		checkerRunning: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "checker_running",
+			Help:      "Whether the checker is currently checking a history.",
+		}),
+		checkerOps: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "checker_operations",
+			Help:      "Number of operations in the history being checked.",
+		}),
+		checkerDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "checker_last_duration_seconds",
+			Help:      "Time taken by the last completed check.",
+		}),
+		checkerPass: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "checker_last_pass",
+			Help:      "Whether the last completed check passed.",
+		}),
+		checksTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "checks_total",
+			Help:      "Number of completed checks by result.",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.latency,
+		m.inFlight,
+		m.checkerRunning,
+		m.checkerOps,
+		m.checkerDuration,
+		m.checkerPass,
+		m.checksTotal,
+	)
+
+	return m
+}
+
+// Serve exposes the metrics on addr under /metrics.
+func (m *Metrics) Serve(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+
+	// bind before the run starts so a taken address fails the run up front
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	m.server = &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		if err := m.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("metrics server stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Shutdown stops the metrics server.
+func (m *Metrics) Shutdown() error {
+	if m.server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return m.server.Shutdown(ctx)
+}
+
+func (m *Metrics) OnInvoke(op store.Operation) {
+	m.inFlight.WithLabelValues(op.API.String()).Inc()
+}
+
+func (m *Metrics) OnComplete(op store.Operation) {
+	api := op.API.String()
+	m.inFlight.WithLabelValues(api).Dec()
+	m.requests.WithLabelValues(api, op.Status.String(), strconv.Itoa(op.Code)).Inc()
+	m.latency.WithLabelValues(api).Observe(op.ReturnEvent.Sub(op.CallEvent).Seconds())
+}
+
+func (m *Metrics) CheckStarted(ops int) {
+	m.checkerRunning.Set(1)
+	m.checkerOps.Set(float64(ops))
+}
+
+func (m *Metrics) CheckFinished(pass bool, elapsed time.Duration) {
+	m.checkerRunning.Set(0)
+	m.checkerDuration.Set(elapsed.Seconds())
+
+	result := "fail"
+	m.checkerPass.Set(0)
+	if pass {
+		result = "pass"
+		m.checkerPass.Set(1)
+	}
+	m.checksTotal.WithLabelValues(result).Inc()
+}
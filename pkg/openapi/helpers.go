@@ -1,7 +1,59 @@
 package openapi
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
 // CompletePromiseRequestWrapper makes life easier since id is not part of the body.
 type CompletePromiseRequestWrapper struct {
 	Id      *string
 	Request interface{}
 }
+
+// ErrorResponse is the payload the server returns for unsuccessful requests.
+type ErrorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// UnmarshalJSON accepts both the nested form {"error": {"code": .., "message": ..}}
+// and the flat form {"code": .., "message": ..}.
+func (e *ErrorResponse) UnmarshalJSON(b []byte) error {
+	type flat ErrorResponse
+	var nested struct {
+		Error *flat `json:"error"`
+	}
+	if err := json.Unmarshal(b, &nested); err != nil {
+		return err
+	}
+	if nested.Error != nil {
+		*e = ErrorResponse(*nested.Error)
+		return nil
+	}
+
+	var f flat
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	*e = ErrorResponse(f)
+	return nil
+}
+
+// NewErrorResponse decodes an error payload, falling back to the raw body
+// as the message when the server does not respond with json.
+func NewErrorResponse(code int, body []byte) *ErrorResponse {
+	var e ErrorResponse
+	if err := json.Unmarshal(body, &e); err != nil || (e.Code == 0 && e.Message == "") {
+		e = ErrorResponse{Message: strings.TrimSpace(string(body))}
+	}
+	if e.Code == 0 {
+		e.Code = code
+	}
+	return &e
+}
+
+func (e *ErrorResponse) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
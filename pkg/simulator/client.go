@@ -15,10 +15,13 @@ import (
 type Client struct {
 	ID     int
 	client openapi.ClientInterface
+
+	// Tracer is optional, when set every operation is wrapped in a span.
+	Tracer *Tracer
 }
 
-func NewClient(id int, conn string) (*Client, error) {
-	c, err := openapi.NewClientWithResponses(conn)
+func NewClient(id int, conn string, opts ...openapi.ClientOption) (*Client, error) {
+	c, err := openapi.NewClientWithResponses(conn, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -30,6 +33,20 @@ func NewClient(id int, conn string) (*Client, error) {
 
 // Invoke receives the start of an operation and returns the end of it
 func (c *Client) Invoke(ctx context.Context, op store.Operation) store.Operation {
+	if c.Tracer == nil {
+		return c.invoke(ctx, op)
+	}
+
+	ctx, span := c.Tracer.Start(ctx, &op)
+	op = c.invoke(ctx, op)
+	c.Tracer.End(span, op)
+
+	return op
+}
+
+func (c *Client) invoke(ctx context.Context, op store.Operation) store.Operation {
+	ctx = withOperation(ctx, op)
+
 	switch op.API {
 	case store.Search:
 		return c.Search(ctx, op)
@@ -138,26 +155,28 @@ func invoke[T any](ctx context.Context, op store.Operation, call func() (*http.R
 	resp, err := call()
 	if err != nil {
 		op.ReturnEvent = time.Now()
+		op.Output = &openapi.ErrorResponse{Message: err.Error()}
 		return op
 	}
 
 	op.ReturnEvent = time.Now()
 
 	op.Code = resp.StatusCode
+	if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+		op.ServerDate = date
+	}
 
 	defer resp.Body.Close()
 	b, err := io.ReadAll(resp.Body)
 	if err != nil {
+		op.Output = &openapi.ErrorResponse{Code: op.Code, Message: err.Error()}
 		return op
 	}
 
-	var out T
-	err = json.Unmarshal(b, &out)
-	if err != nil {
-		return op
+	if resp.Request != nil {
+		op.RequestBytes = requestSize(resp.Request)
 	}
-
-	op.Output = &out
+	op.ResponseBytes = headerSize(resp.Header) + len(resp.Status) + len(resp.Proto) + 3 + len(b)
 
 	op.Status = store.Fail
 	for i := range ok {
@@ -167,5 +186,41 @@ func invoke[T any](ctx context.Context, op store.Operation, call func() (*http.R
 		}
 	}
 
+	if op.Status == store.Fail {
+		op.Output = openapi.NewErrorResponse(op.Code, b)
+		return op
+	}
+
+	var out T
+	err = json.Unmarshal(b, &out)
+	if err != nil {
+		// a success code with an undecodable body is a protocol violation
+		op.Status = store.Fail
+		op.Output = openapi.NewErrorResponse(op.Code, b)
+		return op
+	}
+
+	op.Output = &out
+
 	return op
 }
+
+// requestSize approximates the size of a request on the wire.
+func requestSize(req *http.Request) int {
+	size := len(req.Method) + len(req.URL.RequestURI()) + len(req.Proto) + 4 + headerSize(req.Header)
+	if req.ContentLength > 0 {
+		size += int(req.ContentLength)
+	}
+	return size
+}
+
+// headerSize is the size of the headers as "key: value\r\n" lines.
+func headerSize(h http.Header) int {
+	size := 2
+	for k, vs := range h {
+		for _, v := range vs {
+			size += len(k) + len(v) + 4
+		}
+	}
+	return size
+}
@@ -0,0 +1,166 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+const (
+	// clockProbes is the number of requests the server clock is probed with
+	// before the run.
+	clockProbes = 5
+
+	// clockProbeInterval spreads the probes over a second, the resolution of
+	// the Date header, so they straddle a tick of the server clock.
+	clockProbeInterval = 250 * time.Millisecond
+)
+
+// probeClock reads a promise of the namespace that is never created, the
+// responses carry the server time the offset of its clock is estimated from.
+func probeClock(ctx context.Context, c *Client, namespace string) []store.Operation {
+	probes := make([]store.Operation, 0, clockProbes)
+	for i := 0; i < clockProbes; i++ {
+		if i > 0 {
+			time.Sleep(clockProbeInterval)
+		}
+		probes = append(probes, c.Get(ctx, store.Operation{
+			API:   store.Get,
+			Input: namespace + "-clock-probe",
+		}))
+	}
+	return probes
+}
+
+// A ClockControl moves the clock of a server that exposes a test clock, so
+// promises time out at points of the run chosen by the generator. The clock
+// must only move when it is set.
+type ClockControl interface {
+	// Now returns the time of the server clock.
+	Now(ctx context.Context) (time.Time, error)
+	// Set moves the server clock forward to the given time.
+	Set(ctx context.Context, t time.Time) error
+}
+
+// HTTPClockControl drives a test clock over http. A GET of the hook returns
+// {"time": <unix milliseconds>}, a POST of the same body sets the clock.
+type HTTPClockControl struct {
+	url    string
+	client *http.Client
+	config *HTTPConfig
+}
+
+// NewHTTPClockControl returns a clock control for the hook at the url, it is
+// called with the tls settings, headers and credentials of the run.
+func NewHTTPClockControl(url string, config *HTTPConfig) (*HTTPClockControl, error) {
+	if config == nil {
+		config = &HTTPConfig{}
+	}
+	client, err := config.httpClient(nil)
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = 10 * time.Second
+	return &HTTPClockControl{url: url, client: client, config: config}, nil
+}
+
+type clockBody struct {
+	Time int64 `json:"time"`
+}
+
+func (c *HTTPClockControl) Now(ctx context.Context) (time.Time, error) {
+	var body clockBody
+	if err := c.do(ctx, http.MethodGet, nil, &body); err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(body.Time), nil
+}
+
+func (c *HTTPClockControl) Set(ctx context.Context, t time.Time) error {
+	return c.do(ctx, http.MethodPost, &clockBody{Time: t.UnixMilli()}, nil)
+}
+
+func (c *HTTPClockControl) do(ctx context.Context, method string, in, out *clockBody) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.url, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if err := c.config.editRequest(ctx, req); err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("clock hook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("clock hook: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("clock hook: %s %s returned '%d': %s", method, c.url, resp.StatusCode, bytes.TrimSpace(b))
+	}
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil {
+			return fmt.Errorf("clock hook: %v", err)
+		}
+	}
+	return nil
+}
+
+// Ticks split every round of a run with a controlled clock. Clients wait for
+// each other at the end of a tick and the clock moves forward by the length
+// of a tick before the next one starts.
+type TickConfig struct {
+	// Ticks is the number of ticks per round.
+	Ticks int
+	// Tick is the length of a tick in server time.
+	Tick time.Duration
+}
+
+const (
+	DefaultTicks = 10
+	DefaultTick  = time.Second
+)
+
+func (c TickConfig) Validate() error {
+	if c.Ticks < 1 {
+		return fmt.Errorf("ticks must be at least 1, got %d", c.Ticks)
+	}
+	if c.Tick < time.Millisecond {
+		return fmt.Errorf("tick must be at least 1ms, got %v", c.Tick)
+	}
+	return nil
+}
+
+// tickOf returns the tick the i-th of n operations of a client runs in, every
+// tick runs an even share of the operations.
+func tickOf(i, n, ticks int) int {
+	return i * ticks / n
+}
+
+// at returns the server time of a tick of a round counted from 1, the clock
+// keeps moving forward across rounds.
+func (c TickConfig) at(start time.Time, round, tick int) time.Time {
+	return start.Add(time.Duration((round-1)*c.Ticks+tick) * c.Tick)
+}
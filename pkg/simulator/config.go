@@ -1,7 +1,167 @@
 package simulator
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/checker"
+)
+
 type SimulationConfig struct {
 	Addr        string
 	NumClients  int
 	NumRequests int
+	HTTP        *HTTPConfig
+
+	// Namespace prefixes every promise id and scopes every search so the run
+	// does not collide with other runs or data on the server, CheckNamespace
+	// warns before the run if the namespace already holds promises.
+	Namespace      string
+	CheckNamespace bool
+
+	// Bootstrap seeds the model with a snapshot of the promises already in
+	// the namespace, so runs can repeat against a long lived server.
+	Bootstrap bool
+
+	// Rounds is the number of batches of NumRequests operations per client,
+	// separated by a barrier and checked one by one.
+	Rounds int
+
+	// ClockHook is the url of the test clock of the server, when set every
+	// round is split into ticks and promises time out on them.
+	ClockHook string
+	Ticks     TickConfig
+
+	// Seed seeds the generator, runs with the same seed and settings send
+	// the same operations.
+	Seed int64
+
+	// Out is the directory runs are written to and RunName the directory of
+	// this run within it, defaulting to the start time of the run.
+	Out     string
+	RunName string
+
+	// UniqueValues makes every written value encode the operation that wrote
+	// it, followed by a payload shaped by Payload.
+	UniqueValues bool
+	Payload      PayloadConfig
+
+	// Fuzz is the fraction of operations replaced by malformed or edge case inputs.
+	Fuzz float64
+
+	// Workload shapes which promises operations target.
+	Workload WorkloadConfig
+
+	// Capture records every http exchange to a har file in the results directory.
+	Capture bool
+
+	// Thresholds are performance objectives evaluated after the run.
+	Thresholds []checker.Threshold
+
+	// Engines check the history for linearizability.
+	Engines []checker.Engine
+
+	// Models are the consistency models the history must satisfy.
+	Models []checker.ConsistencyModel
+
+	// Tracing is optional, when set every operation is exported as a span.
+	Tracing *TracingConfig
+
+	// MetricsAddr, if set, is the address prometheus metrics are served on during the run.
+	MetricsAddr string
+}
+
+// Describe returns the settings of the run shown in the report, credentials are left out.
+func (c *SimulationConfig) Describe() map[string]string {
+	config := map[string]string{
+		"addr":     c.Addr,
+		"clients":  strconv.Itoa(c.NumClients),
+		"requests": strconv.Itoa(c.NumRequests),
+		"capture":  strconv.FormatBool(c.Capture),
+	}
+	if c.Namespace != "" {
+		config["namespace"] = c.Namespace
+	}
+	if c.Rounds > 1 {
+		config["rounds"] = strconv.Itoa(c.Rounds)
+	}
+	if c.ClockHook != "" {
+		config["clock hook"] = c.ClockHook
+		config["ticks"] = fmt.Sprintf("%d of %v", c.Ticks.Ticks, c.Ticks.Tick)
+	}
+	if c.Seed != 0 {
+		config["seed"] = strconv.FormatInt(c.Seed, 10)
+	}
+	if c.UniqueValues {
+		config["values"] = "unique"
+	}
+	if c.Fuzz > 0 {
+		config["fuzz"] = strconv.FormatFloat(c.Fuzz, 'f', -1, 64)
+	}
+	if c.Payload.Enabled() {
+		config["payload"] = c.Payload.String()
+	}
+	if c.Workload.Enabled() {
+		config["workload"] = c.Workload.String()
+	}
+	if len(c.Engines) > 0 {
+		engines := make([]string, len(c.Engines))
+		for i, e := range c.Engines {
+			engines[i] = string(e)
+		}
+		config["checker"] = strings.Join(engines, ", ")
+	}
+	if len(c.Models) > 0 {
+		models := make([]string, len(c.Models))
+		for i, m := range c.Models {
+			models[i] = string(m)
+		}
+		config["consistency"] = strings.Join(models, ", ")
+	}
+	if len(c.Thresholds) > 0 {
+		exprs := make([]string, len(c.Thresholds))
+		for i, t := range c.Thresholds {
+			exprs[i] = t.Raw
+		}
+		config["thresholds"] = strings.Join(exprs, ", ")
+	}
+	if c.Tracing != nil {
+		config["tracing"] = c.Tracing.Exporter
+	}
+	if c.MetricsAddr != "" {
+		config["metrics"] = c.MetricsAddr
+	}
+	return config
+}
+
+// HTTPConfig holds the authentication, header and tls settings used by every client.
+type HTTPConfig struct {
+	Username    string
+	Password    string
+	BearerToken string
+	Headers     map[string]string
+
+	CACert             string
+	ClientCert         string
+	ClientKey          string
+	InsecureSkipVerify bool
+}
+
+// ParseHeaders parses headers given as key=value or key: value. Only the first
+// separator splits the entry, so values may contain commas, '=' and ':'.
+func ParseHeaders(entries []string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, entry := range entries {
+		i := strings.IndexAny(entry, "=:")
+		if i <= 0 {
+			return nil, fmt.Errorf("header '%s' must be key=value or key: value", entry)
+		}
+		key := strings.TrimSpace(entry[:i])
+		if key == "" {
+			return nil, fmt.Errorf("header '%s' has an empty key", entry)
+		}
+		headers[key] = strings.TrimSpace(entry[i+1:])
+	}
+	return headers, nil
 }
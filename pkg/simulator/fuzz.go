@@ -0,0 +1,192 @@
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
+)
+
+// A fuzzCase turns a promise id into a malformed or edge case operation.
+type fuzzCase struct {
+	name string
+	// mayAccept is set for edge cases that are valid input.
+	mayAccept bool
+	// reject lists the status codes that are correct when the input is rejected.
+	reject []int
+	// unusualID is set when the case mangles the id, the follow up read must
+	// then be prepared for the id to be rejected too.
+	unusualID bool
+	op        func(r *rand.Rand, id string) (store.API, interface{})
+}
+
+var (
+	badRequest   = []int{http.StatusBadRequest, http.StatusUnprocessableEntity}
+	notFound     = append([]int{http.StatusNotFound}, badRequest...)
+	tooLarge     = append([]int{http.StatusRequestEntityTooLarge}, badRequest...)
+	uriTooLong   = append([]int{http.StatusNotFound, http.StatusRequestURITooLong, http.StatusRequestHeaderFieldsTooLarge}, tooLarge...)
+	badPatch     = append([]int{http.StatusNotFound, http.StatusForbidden}, badRequest...)
+	emptyIDPatch = append([]int{http.StatusNotFound, http.StatusMethodNotAllowed}, badRequest...)
+)
+
+const (
+	hugeIDLength = 16 * 1024
+	tagCount     = 512
+	tagLength    = 1024
+	badBase64    = "%%% not base64 %%%"
+)
+
+var fuzzCases = []fuzzCase{
+	{name: "empty id", reject: badRequest, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Create, createRequest("", validParam(), 2524608000000)
+	}},
+	{name: "negative timeout", reject: append([]int{http.StatusConflict}, badRequest...), op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Create, createRequest(id, validParam(), -1)
+	}},
+	{name: "bad base64 param", reject: append([]int{http.StatusConflict}, badRequest...), op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Create, createRequest(id, &openapi.PromiseValue{Data: utils.ToPointer(badBase64)}, 2524608000000)
+	}},
+	{name: "oversized tags", mayAccept: true, reject: append([]int{http.StatusConflict}, tooLarge...), op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		body := createRequest(id, validParam(), 2524608000000)
+		tags := make(map[string]string, tagCount)
+		for i := 0; i < tagCount; i++ {
+			tags[fmt.Sprintf("tag-%d", i)] = randomString(r, tagLength)
+		}
+		body.Tags = &tags
+		return store.Create, body
+	}},
+	{name: "unicode id", mayAccept: true, reject: append([]int{http.StatusConflict}, badRequest...), unusualID: true, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Create, createRequest(unicodeID(id), validParam(), 2524608000000)
+	}},
+	{name: "path traversal id", mayAccept: true, reject: append([]int{http.StatusConflict}, notFound...), unusualID: true, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Create, createRequest(traversalID(r, id), validParam(), 2524608000000)
+	}},
+	{name: "huge id", mayAccept: true, reject: append([]int{http.StatusConflict}, tooLarge...), unusualID: true, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Create, createRequest(hugeID(id), validParam(), 2524608000000)
+	}},
+	{name: "invalid state", reject: badPatch, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Resolve, completeRequest(id, "BOGUS", validParam())
+	}},
+	{name: "pending state", reject: badPatch, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Resolve, completeRequest(id, openapi.PromiseStateComplete(openapi.PromiseStatePENDING), validParam())
+	}},
+	{name: "bad base64 value", reject: badPatch, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Resolve, completeRequest(id, openapi.PromiseStateCompleteRESOLVED, &openapi.PromiseValue{Data: utils.ToPointer(badBase64)})
+	}},
+	{name: "empty id", reject: emptyIDPatch, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Reject, completeRequest("", openapi.PromiseStateCompleteREJECTED, validParam())
+	}},
+	{name: "path traversal id", mayAccept: true, reject: badPatch, unusualID: true, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Cancel, completeRequest(traversalID(r, id), openapi.PromiseStateCompleteREJECTEDCANCELED, validParam())
+	}},
+	{name: "unknown search state", reject: badRequest, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		state := openapi.SearchPromisesParamsState("bogus")
+		return store.Search, &openapi.SearchPromisesParams{Id: utils.ToPointer("*"), State: &state}
+	}},
+	{name: "unicode id", mayAccept: true, reject: notFound, unusualID: true, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Get, unicodeID(id)
+	}},
+	{name: "path traversal id", mayAccept: true, reject: notFound, unusualID: true, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Get, traversalID(r, id)
+	}},
+	{name: "huge id", mayAccept: true, reject: uriTooLong, unusualID: true, op: func(r *rand.Rand, id string) (store.API, interface{}) {
+		return store.Get, hugeID(id)
+	}},
+}
+
+// fuzzes draws whether the next operations of a client are a fuzz case, which
+// is only drawn when the case and the read that follows it both fit in the
+// requests of the client, so the read is never cut off.
+func (g *Generator) fuzzes(n int) bool {
+	return g.fuzz > 0 && n+2 <= g.numRequests && g.r.Float64() < g.fuzz
+}
+
+// GenerateFuzz returns a malformed or edge case operation followed by a read
+// of the promise it targets, which confirms the state did not change.
+func (g *Generator) GenerateFuzz(r *rand.Rand, clientID int) []store.Operation {
+	c := fuzzCases[r.Intn(len(fuzzCases))]
+	api, input := c.op(r, g.scoped(g.id(r)))
+
+	ops := []store.Operation{{
+		ID:       g.opID(),
+		ClientID: clientID,
+		API:      api,
+		Input:    input,
+		Fuzz:     &store.Fuzz{Name: c.name, MayAccept: c.mayAccept, Reject: c.reject},
+	}}
+
+	id, ok := fuzzTarget(input)
+	if !ok || id == "" {
+		return ops
+	}
+
+	read := store.Operation{
+		ID:       g.opID(),
+		ClientID: clientID,
+		API:      store.Get,
+		Input:    id,
+	}
+	if c.unusualID {
+		read.Fuzz = &store.Fuzz{Name: c.name + " read", MayAccept: true, Reject: uriTooLong}
+	}
+
+	return append(ops, read)
+}
+
+func fuzzTarget(input interface{}) (string, bool) {
+	switch v := input.(type) {
+	case *openapi.CreatePromiseJSONRequestBody:
+		return v.Id, true
+	case *openapi.CompletePromiseRequestWrapper:
+		return utils.SafeDereference(v.Id), true
+	case string:
+		return v, true
+	default:
+		return "", false
+	}
+}
+
+func createRequest(id string, param *openapi.PromiseValue, timeout int64) *openapi.CreatePromiseJSONRequestBody {
+	return &openapi.CreatePromiseJSONRequestBody{Id: id, Param: param, Timeout: timeout}
+}
+
+func completeRequest(id string, state openapi.PromiseStateComplete, value *openapi.PromiseValue) *openapi.CompletePromiseRequestWrapper {
+	return &openapi.CompletePromiseRequestWrapper{
+		Id: utils.ToPointer(id),
+		Request: &openapi.PatchPromisesIdJSONRequestBody{
+			State: state,
+			Value: value,
+		},
+	}
+}
+
+func validParam() *openapi.PromiseValue {
+	return &openapi.PromiseValue{Data: utils.ToPointer("")}
+}
+
+// the mangled ids below keep the namespaced id as their prefix, so they stay
+// within the namespace of the run. Traversals resolve to an id that is never
+// created, a server that cleans the path must not find another promise.
+
+func unicodeID(id string) string {
+	return id + "-ünïcødé-日本-🙂"
+}
+
+func traversalID(r *rand.Rand, id string) string {
+	switch r.Intn(3) {
+	case 0:
+		return id + "/../" + id + "-traversed"
+	case 1:
+		return id + "/./../" + id + "-traversed"
+	default:
+		return id + "..%2F..%2F" + id
+	}
+}
+
+func hugeID(id string) string {
+	return id + "-" + strings.Repeat("x", hugeIDLength)
+}
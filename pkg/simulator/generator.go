@@ -2,10 +2,11 @@ package simulator
 
 import (
 	"encoding/base64"
+	"fmt"
 	"math/rand"
 	"strconv"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
 	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
 	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
@@ -18,15 +19,62 @@ type GeneratorConfig struct {
 
 	Ids  int
 	Data int
+
+	// UniqueValues makes every create param and completion value encode the
+	// operation that wrote it, so any observed value points to exactly one
+	// write. Payload shapes the filler added to unique values and the
+	// headers set on every value.
+	UniqueValues bool
+	Payload      PayloadConfig
+
+	// Fuzz is the fraction of operations replaced by malformed or edge case
+	// inputs, each followed by a read of the promise it targets.
+	Fuzz float64
+
+	// Workload shapes which promises operations target.
+	Workload WorkloadConfig
+
+	// Namespace prefixes every promise id and scopes every search, so runs
+	// never see each other's promises.
+	Namespace string
+
+	// Ticks places timeouts on the ticks of a controlled server clock once
+	// the generator is scheduled.
+	Ticks TickConfig
 }
 
 type Generator struct {
-	r           *rand.Rand
-	numRequests int
-	idSet       []string
-	dataSet     [][]byte
+	r            *rand.Rand
+	numRequests  int
+	idSet        []string
+	dataSet      [][]byte
+	uniqueValues bool
+	payload      PayloadConfig
+	fuzz         float64
+	workload     WorkloadConfig
+	zipf         *rand.Zipf
+
+	// every id is prefixed with the namespace, the namespace of the run
+	// followed by the round in runs with several rounds
+	prefix    string
+	round     int
+	namespace string
+
+	// start is the server time of the first tick of the round, it is zero
+	// unless the server clock is controlled
+	ticks TickConfig
+	start time.Time
+
+	// nextID numbers the operations of the run
+	nextID int
 }
 
+// opIDBits is the room left for the operations of a run below the seeded
+// start of its ids, which keeps values written by earlier runs in the same
+// namespace from pointing to operations of this one. Ids stay below 2^53 so
+// they are exact in the visualizations.
+const opIDBits = 24
+
 func NewGenerator(config *GeneratorConfig) *Generator {
 	idSet := make([]string, config.Ids)
 	for i := 0; i < config.Ids; i++ {
@@ -38,15 +86,77 @@ func NewGenerator(config *GeneratorConfig) *Generator {
 		dataSet = append(dataSet, []byte(strconv.Itoa(i)), nil) // half of all values are nil
 	}
 
+	prefix := ""
+	if config.Namespace != "" {
+		prefix = config.Namespace + separator
+	}
+
 	return &Generator{
-		r:           config.r,
-		numRequests: config.numRequests,
-		idSet:       idSet,
-		dataSet:     dataSet,
+		r:            config.r,
+		numRequests:  config.numRequests,
+		idSet:        idSet,
+		dataSet:      dataSet,
+		uniqueValues: config.UniqueValues,
+		payload:      config.Payload,
+		fuzz:         config.Fuzz,
+		workload:     config.Workload,
+		zipf:         config.Workload.zipf(config.r, len(idSet)),
+		prefix:       prefix,
+		namespace:    prefix,
+		nextID:       int(config.r.Int31n(1<<(53-opIDBits-1))) << opIDBits,
+		ticks:        config.Ticks,
 	}
 }
 
+// Round starts a round of a run with several rounds. Rounds never share
+// promises, so each can be checked on its own.
+func (g *Generator) Round(round int) {
+	g.round = round
+	g.namespace = fmt.Sprintf("%sround-%d%s", g.prefix, round, separator)
+}
+
+// Schedule sets the server time of the first tick of the round, timeouts of
+// the promises created in the round are then placed on its ticks.
+func (g *Generator) Schedule(start time.Time) {
+	g.start = start
+}
+
 func (g *Generator) Generate(clientId int) []store.Operation {
+	var ops []store.Operation
+	switch g.workload.Mode {
+	case RaceWorkload:
+		ops = g.generateRace(clientId)
+	case LifecycleWorkload:
+		ops = g.generateLifecycle(clientId)
+	default:
+		ops = g.generate(clientId)
+	}
+
+	for i := range ops {
+		ops[i].Round = g.round
+	}
+	if !g.start.IsZero() {
+		g.scheduleTimeouts(ops)
+	}
+	return ops
+}
+
+// scheduleTimeouts has half of the promises time out a whole number of ticks
+// after the tick they are created in. Timeouts fall halfway through a tick so
+// the server clock is well clear of them whenever it is read.
+func (g *Generator) scheduleTimeouts(ops []store.Operation) {
+	for i := range ops {
+		req, ok := ops[i].Input.(*openapi.CreatePromiseJSONRequestBody)
+		if !ok || req == nil || ops[i].Fuzz != nil || g.r.Intn(2) == 0 {
+			continue
+		}
+		created := tickOf(i, len(ops), g.ticks.Ticks)
+		ticks := created + 1 + g.r.Intn(g.ticks.Ticks-created)
+		req.Timeout = g.start.Add(time.Duration(ticks)*g.ticks.Tick - g.ticks.Tick/2).UnixMilli()
+	}
+}
+
+func (g *Generator) generate(clientId int) []store.Operation {
 	ops := []store.Operation{}
 
 	generators := []OpGenerator{
@@ -58,7 +168,11 @@ func (g *Generator) Generate(clientId int) []store.Operation {
 		g.GenerateRejectPromise,
 	}
 
-	for i := 0; i < g.numRequests; i++ {
+	for len(ops) < g.numRequests {
+		if g.fuzzes(len(ops)) {
+			ops = append(ops, g.GenerateFuzz(g.r, clientId)...)
+			continue
+		}
 		bound := len(generators)
 		ops = append(ops, generators[g.r.Intn(bound)](g.r, clientId))
 	}
@@ -66,6 +180,26 @@ func (g *Generator) Generate(clientId int) []store.Operation {
 	return ops
 }
 
+// generateRace has every client step through the same rounds, each round
+// creating and completing a handful of fresh promises and reading one back, so
+// concurrent clients race on the same ids at roughly the same time.
+func (g *Generator) generateRace(clientId int) []store.Operation {
+	ops := []store.Operation{}
+
+	for round := 0; len(ops) < g.numRequests; round++ {
+		key := func() string {
+			return raceID(round, g.r.Intn(g.workload.HotKeys))
+		}
+		ops = append(ops,
+			g.createPromise(g.r, clientId, key()),
+			g.completePromise(g.r, clientId, completions[g.r.Intn(len(completions))], key()),
+			g.readPromise(clientId, key()),
+		)
+	}
+
+	return ops[:g.numRequests]
+}
+
 type OpGenerator func(*rand.Rand, int) store.Operation
 
 func (g *Generator) GenerateSearchPromise(r *rand.Rand, clientID int) store.Operation {
@@ -84,11 +218,11 @@ func (g *Generator) GenerateSearchPromise(r *rand.Rand, clientID int) store.Oper
 	stateParam := openapi.SearchPromisesParamsState(state)
 
 	return store.Operation{
-		ID:       int(uuid.New().ID()),
+		ID:       g.opID(),
 		ClientID: clientID,
 		API:      store.Search,
 		Input: &openapi.SearchPromisesParams{
-			Id:    utils.ToPointer("*"),
+			Id:    utils.ToPointer(g.namespace + "*"),
 			State: &stateParam,
 			// Limit: utils.ToPointer(),
 			// Cursor: utils.ToPointer(),
@@ -97,91 +231,111 @@ func (g *Generator) GenerateSearchPromise(r *rand.Rand, clientID int) store.Oper
 }
 
 func (g *Generator) GenerateReadPromise(r *rand.Rand, clientID int) store.Operation {
-	promiseId := g.idSet[r.Intn(len(g.idSet))]
+	return g.readPromise(clientID, g.id(r))
+}
+
+func (g *Generator) GenerateCreatePromise(r *rand.Rand, clientID int) store.Operation {
+	return g.createPromise(r, clientID, g.id(r))
+}
+
+func (g *Generator) GenerateCancelPromise(r *rand.Rand, clientID int) store.Operation {
+	return g.completePromise(r, clientID, store.Cancel, g.id(r))
+}
+
+func (g *Generator) GenerateResolvePromise(r *rand.Rand, clientID int) store.Operation {
+	return g.completePromise(r, clientID, store.Resolve, g.id(r))
+}
 
+func (g *Generator) GenerateRejectPromise(r *rand.Rand, clientID int) store.Operation {
+	return g.completePromise(r, clientID, store.Reject, g.id(r))
+}
+
+// id picks the promise an operation targets, skewed towards hot keys in the
+// hot-key workload.
+func (g *Generator) id(r *rand.Rand) string {
+	if g.zipf != nil {
+		return g.idSet[g.zipf.Uint64()]
+	}
+	return g.idSet[r.Intn(len(g.idSet))]
+}
+
+// opID returns the id of the next operation. Ids count up from a start drawn
+// from the seeded source, so they never collide and runs with the same seed
+// number their operations the same.
+func (g *Generator) opID() int {
+	g.nextID++
+	return g.nextID
+}
+
+// scoped returns the id of a promise within the namespace.
+func (g *Generator) scoped(promiseId string) string {
+	return g.namespace + promiseId
+}
+
+func (g *Generator) readPromise(clientID int, promiseId string) store.Operation {
+	promiseId = g.scoped(promiseId)
 	return store.Operation{
-		ID:       int(uuid.New().ID()),
+		ID:       g.opID(),
 		ClientID: clientID,
 		API:      store.Get,
 		Input:    promiseId,
 	}
 }
 
-func (g *Generator) GenerateCreatePromise(r *rand.Rand, clientID int) store.Operation {
-	promiseId := g.idSet[r.Intn(len(g.idSet))]
-	data := g.dataSet[r.Intn(len(g.dataSet))]
+func (g *Generator) createPromise(r *rand.Rand, clientID int, promiseId string) store.Operation {
+	promiseId = g.scoped(promiseId)
+	id := g.opID()
+	value := g.value(r, id, clientID)
 	// timeout := r.Int63n(max-min) + min
 
 	return store.Operation{
-		ID:       int(uuid.New().ID()),
+		ID:       id,
 		ClientID: clientID,
 		API:      store.Create,
 		Input: &openapi.CreatePromiseJSONRequestBody{
-			Id: promiseId,
-			Param: &openapi.PromiseValue{
-				Data: utils.ToPointer(base64.StdEncoding.EncodeToString(data)),
-			},
+			Id:      promiseId,
+			Param:   value,
 			Timeout: 2524608000000,
 		},
 	}
 }
 
-func (g *Generator) GenerateCancelPromise(r *rand.Rand, clientID int) store.Operation {
-	promiseId := g.idSet[r.Intn(len(g.idSet))]
-	data := g.dataSet[r.Intn(len(g.dataSet))]
-
-	return store.Operation{
-		ID:       int(uuid.New().ID()),
-		ClientID: clientID,
-		API:      store.Cancel,
-		Input: &openapi.CompletePromiseRequestWrapper{
-			Id: utils.ToPointer(promiseId),
-			Request: &openapi.PatchPromisesIdJSONRequestBody{
-				State: openapi.PromiseStateCompleteREJECTEDCANCELED,
-				Value: &openapi.PromiseValue{
-					Data: utils.ToPointer(base64.StdEncoding.EncodeToString(data)),
-				},
-			},
-		},
-	}
+var completeStates = map[store.API]openapi.PromiseStateComplete{
+	store.Cancel:  openapi.PromiseStateCompleteREJECTEDCANCELED,
+	store.Resolve: openapi.PromiseStateCompleteRESOLVED,
+	store.Reject:  openapi.PromiseStateCompleteREJECTED,
 }
 
-func (g *Generator) GenerateResolvePromise(r *rand.Rand, clientID int) store.Operation {
-	promiseId := g.idSet[r.Intn(len(g.idSet))]
-	data := g.dataSet[r.Intn(len(g.dataSet))]
+func (g *Generator) completePromise(r *rand.Rand, clientID int, api store.API, promiseId string) store.Operation {
+	promiseId = g.scoped(promiseId)
+	id := g.opID()
+	value := g.value(r, id, clientID)
 
 	return store.Operation{
-		ID:       int(uuid.New().ID()),
+		ID:       id,
 		ClientID: clientID,
-		API:      store.Resolve,
+		API:      api,
 		Input: &openapi.CompletePromiseRequestWrapper{
 			Id: utils.ToPointer(promiseId),
 			Request: &openapi.PatchPromisesIdJSONRequestBody{
-				State: openapi.PromiseStateCompleteRESOLVED,
-				Value: &openapi.PromiseValue{
-					Data: utils.ToPointer(base64.StdEncoding.EncodeToString(data)),
-				},
+				State: completeStates[api],
+				Value: value,
 			},
 		},
 	}
 }
 
-func (g *Generator) GenerateRejectPromise(r *rand.Rand, clientID int) store.Operation {
-	promiseId := g.idSet[r.Intn(len(g.idSet))]
-	data := g.dataSet[r.Intn(len(g.dataSet))]
+// value returns the value written by an operation.
+func (g *Generator) value(r *rand.Rand, opID, clientID int) *openapi.PromiseValue {
+	var data []byte
+	if g.uniqueValues {
+		data = store.EncodeValue(opID, clientID, g.payload.payload(r))
+	} else {
+		data = g.dataSet[r.Intn(len(g.dataSet))]
+	}
 
-	return store.Operation{
-		ID:       int(uuid.New().ID()),
-		ClientID: clientID,
-		API:      store.Reject,
-		Input: &openapi.CompletePromiseRequestWrapper{
-			Id: utils.ToPointer(promiseId),
-			Request: &openapi.PatchPromisesIdJSONRequestBody{
-				State: openapi.PromiseStateCompleteREJECTED,
-				Value: &openapi.PromiseValue{
-					Data: utils.ToPointer(base64.StdEncoding.EncodeToString(data)),
-				},
-			},
-		},
+	return &openapi.PromiseValue{
+		Data:    utils.ToPointer(base64.StdEncoding.EncodeToString(data)),
+		Headers: g.payload.headers(r),
 	}
 }
@@ -0,0 +1,107 @@
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+// A lifecycle is a promise a client walks through create, get, complete and
+// a final read.
+type lifecycle struct {
+	id   string
+	step int
+}
+
+const (
+	// lifecycleSteps is the number of operations of a lifecycle.
+	lifecycleSteps = 4
+
+	// maxLifecycles is the number of lifecycles a client interleaves.
+	maxLifecycles = 4
+)
+
+var completions = []store.API{store.Resolve, store.Reject, store.Cancel}
+
+// lifecycleID names the n-th promise of a client, clients never share them.
+func lifecycleID(clientID, n int) string {
+	return fmt.Sprintf("lifecycle-%d-%d", clientID, n)
+}
+
+// generateLifecycle has every client create its own promises and walk them
+// through their states, so most operations take the successful path. Steps
+// stray into invalid transitions with the configured probability.
+func (g *Generator) generateLifecycle(clientId int) []store.Operation {
+	ops := []store.Operation{}
+	active := []*lifecycle{}
+	created, completed := []string{}, []string{}
+	next := 0
+
+	for len(ops) < g.numRequests {
+		if g.fuzzes(len(ops)) {
+			ops = append(ops, g.GenerateFuzz(g.r, clientId)...)
+			continue
+		}
+
+		if g.workload.Stray > 0 && g.r.Float64() < g.workload.Stray {
+			ops = append(ops, g.stray(g.r, clientId, created, completed, &next))
+			continue
+		}
+
+		if len(active) == 0 || (len(active) < maxLifecycles && g.r.Intn(2) == 0) {
+			active = append(active, &lifecycle{id: lifecycleID(clientId, next)})
+			next++
+		}
+
+		i := g.r.Intn(len(active))
+		l := active[i]
+		switch l.step {
+		case 0:
+			ops = append(ops, g.createPromise(g.r, clientId, l.id))
+			created = append(created, l.id)
+		case 1:
+			ops = append(ops, g.readPromise(clientId, l.id))
+		case 2:
+			ops = append(ops, g.completePromise(g.r, clientId, completions[g.r.Intn(len(completions))], l.id))
+			completed = append(completed, l.id)
+		default:
+			if g.r.Intn(2) == 0 {
+				ops = append(ops, g.readPromise(clientId, l.id))
+			} else {
+				ops = append(ops, g.GenerateSearchPromise(g.r, clientId))
+			}
+		}
+
+		l.step++
+		if l.step == lifecycleSteps {
+			active = append(active[:i], active[i+1:]...)
+		}
+	}
+
+	return ops
+}
+
+// stray returns an invalid transition: creating a promise twice, completing
+// one twice, or completing or reading one that was never created.
+func (g *Generator) stray(r *rand.Rand, clientID int, created, completed []string, next *int) store.Operation {
+	api := completions[r.Intn(len(completions))]
+
+	switch r.Intn(4) {
+	case 0:
+		if len(created) > 0 {
+			return g.createPromise(r, clientID, created[r.Intn(len(created))])
+		}
+	case 1:
+		if len(completed) > 0 {
+			return g.completePromise(r, clientID, api, completed[r.Intn(len(completed))])
+		}
+	case 2:
+		// ids are never reused, so this one is never created
+		*next++
+		return g.readPromise(clientID, lifecycleID(clientID, *next-1))
+	}
+
+	*next++
+	return g.completePromise(r, clientID, api, lifecycleID(clientID, *next-1))
+}
@@ -0,0 +1,104 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
+)
+
+// NewNamespace returns a namespace unique to a run, or derived from the seed
+// of a seeded run so that runs with the same seed use the same promise ids.
+func NewNamespace(seed int64) string {
+	if seed != 0 {
+		return fmt.Sprintf("harness-%08x", rand.New(rand.NewSource(seed)).Uint32())
+	}
+	return "harness-" + strings.Split(uuid.NewString(), "-")[0]
+}
+
+// separator ends the namespace of an id. It may not appear in a namespace,
+// otherwise a namespace such as 'staging' would match the ids of 'staging.2'.
+const separator = "."
+
+// ValidateNamespace checks a namespace can prefix ids and search patterns.
+func ValidateNamespace(namespace string) error {
+	if strings.ContainsAny(namespace, "*/"+separator) {
+		return fmt.Errorf("namespace '%s' must not contain '*', '/' or '%s'", namespace, separator)
+	}
+	return nil
+}
+
+// existingPromises searches the namespace for promises that were there before
+// the run, the first page of results is enough to tell it is not empty.
+func existingPromises(ctx context.Context, c *Client, namespace string) (int, error) {
+	op := c.Search(ctx, store.Operation{
+		API:   store.Search,
+		Input: &openapi.SearchPromisesParams{Id: utils.ToPointer(namespace + separator + "*")},
+	})
+	if op.Status != store.Ok {
+		return 0, fmt.Errorf("search of namespace '%s' failed with '%d': %v", namespace, op.Code, op.Output)
+	}
+
+	resp, ok := op.Output.(*openapi.SearchPromisesResponseObj)
+	if !ok || resp.Promises == nil {
+		return 0, nil
+	}
+	return len(*resp.Promises), nil
+}
+
+// snapshotLimit is the page size of the snapshot searches.
+const snapshotLimit = 100
+
+// snapshot returns every promise in the namespace, following the cursor of a
+// search per state until the last page. Nothing else may write to the
+// namespace while the snapshot is taken.
+func snapshot(ctx context.Context, c *Client, namespace string) ([]openapi.Promise, error) {
+	promises := []openapi.Promise{}
+	seen := map[string]bool{}
+
+	states := []openapi.SearchPromisesParamsState{openapi.Pending, openapi.Resolved, openapi.Rejected}
+	for i := range states {
+		var cursor *string
+		for {
+			op := c.Search(ctx, store.Operation{
+				API: store.Search,
+				Input: &openapi.SearchPromisesParams{
+					Id:     utils.ToPointer(namespace + separator + "*"),
+					State:  &states[i],
+					Limit:  utils.ToPointer(snapshotLimit),
+					Cursor: cursor,
+				},
+			})
+			if op.Status != store.Ok {
+				return nil, fmt.Errorf("search of %s promises in namespace '%s' failed with '%d': %v", states[i], namespace, op.Code, op.Output)
+			}
+
+			resp, ok := op.Output.(*openapi.SearchPromisesResponseObj)
+			if !ok {
+				return nil, fmt.Errorf("unexpected search response %T", op.Output)
+			}
+			for _, p := range utils.SafeDereference(resp.Promises) {
+				if !seen[p.Id] {
+					seen[p.Id] = true
+					promises = append(promises, p)
+				}
+			}
+
+			next := utils.SafeDereference(resp.Cursor)
+			if next == "" {
+				break
+			}
+			if next == utils.SafeDereference(cursor) {
+				return nil, fmt.Errorf("search of %s promises in namespace '%s' returned the same cursor twice", states[i], namespace)
+			}
+			cursor = &next
+		}
+	}
+
+	return promises, nil
+}
@@ -0,0 +1,134 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// PayloadConfig shapes the payloads and headers added to written values.
+type PayloadConfig struct {
+	// Distribution of payload sizes, one of "fixed", "uniform" or "heavy-tail".
+	Distribution string
+	// Size is the size of a fixed payload, the upper bound of a uniform one
+	// and the minimum of a heavy tailed one.
+	Size int
+	// Max caps heavy tailed payloads.
+	Max int
+	// Binary fills payloads with random bytes instead of alphanumerics.
+	Binary bool
+	// Headers is the maximum number of random headers set on params and values.
+	Headers int
+}
+
+const (
+	FixedPayload     = "fixed"
+	UniformPayload   = "uniform"
+	HeavyTailPayload = "heavy-tail"
+
+	// DefaultPayloadMax caps heavy tailed payloads at a few megabytes.
+	DefaultPayloadMax = 4 * 1024 * 1024
+
+	// paretoShape gives a tail where most payloads are small but a few are
+	// orders of magnitude larger.
+	paretoShape = 1.2
+)
+
+// Enabled reports whether the config adds anything to written values.
+func (c PayloadConfig) Enabled() bool {
+	return c.Size > 0 || c.Headers > 0
+}
+
+// Validate checks the distribution is known and sizes are sensible.
+func (c PayloadConfig) Validate() error {
+	switch c.Distribution {
+	case "", FixedPayload, UniformPayload, HeavyTailPayload:
+	default:
+		return fmt.Errorf("unknown payload distribution '%s', expected one of: %s, %s, %s", c.Distribution, FixedPayload, UniformPayload, HeavyTailPayload)
+	}
+	if c.Size < 0 || c.Max < 0 || c.Headers < 0 {
+		return fmt.Errorf("payload sizes and header counts must not be negative")
+	}
+	return nil
+}
+
+func (c PayloadConfig) String() string {
+	dist := c.Distribution
+	if dist == "" {
+		dist = FixedPayload
+	}
+	content := "text"
+	if c.Binary {
+		content = "binary"
+	}
+	s := fmt.Sprintf("%s %d bytes %s", dist, c.Size, content)
+	if dist == HeavyTailPayload {
+		s += fmt.Sprintf(" up to %d bytes", c.max())
+	}
+	if c.Headers > 0 {
+		s += fmt.Sprintf(", up to %d headers", c.Headers)
+	}
+	return s
+}
+
+func (c PayloadConfig) max() int {
+	if c.Max > 0 {
+		return c.Max
+	}
+	return DefaultPayloadMax
+}
+
+// size draws the size of the next payload.
+func (c PayloadConfig) size(r *rand.Rand) int {
+	if c.Size <= 0 {
+		return 0
+	}
+	switch c.Distribution {
+	case UniformPayload:
+		return r.Intn(c.Size + 1)
+	case HeavyTailPayload:
+		// pareto distributed with the configured size as its minimum
+		u := 1 - r.Float64() // (0, 1]
+		size := float64(c.Size) / math.Pow(u, 1/paretoShape)
+		return int(math.Min(size, float64(c.max())))
+	default:
+		return c.Size
+	}
+}
+
+const payloadAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// payload draws the next payload.
+func (c PayloadConfig) payload(r *rand.Rand) []byte {
+	b := make([]byte, c.size(r))
+	if c.Binary {
+		r.Read(b)
+		return b
+	}
+	for i := range b {
+		b[i] = payloadAlphabet[r.Intn(len(payloadAlphabet))]
+	}
+	return b
+}
+
+// headers draws a random header map, or nil when headers are disabled.
+func (c PayloadConfig) headers(r *rand.Rand) map[string]string {
+	if c.Headers <= 0 {
+		return nil
+	}
+	n := r.Intn(c.Headers + 1)
+	headers := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		headers["x-"+randomString(r, 1+r.Intn(16))] = randomString(r, r.Intn(64))
+	}
+	return headers
+}
+
+func randomString(r *rand.Rand, n int) string {
+	build := strings.Builder{}
+	for i := 0; i < n; i++ {
+		build.WriteByte(payloadAlphabet[r.Intn(len(payloadAlphabet))])
+	}
+	return build.String()
+}
@@ -0,0 +1,309 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/artifacts"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+)
+
+type operationKey struct{}
+
+// withOperation tags the context so the recorder can link an exchange to its operation.
+func withOperation(ctx context.Context, op store.Operation) context.Context {
+	return context.WithValue(ctx, operationKey{}, op)
+}
+
+func operationFromContext(ctx context.Context) (store.Operation, bool) {
+	op, ok := ctx.Value(operationKey{}).(store.Operation)
+	return op, ok
+}
+
+// redacted headers are never written to the capture file.
+var redacted = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+}
+
+// Recorder is a http.RoundTripper that captures every request and response
+// that goes over the wire, keyed by the operation that issued it.
+type Recorder struct {
+	next    http.RoundTripper
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		entries: make([]harEntry, 0),
+	}
+}
+
+// Wrap returns the recorder as a round tripper that forwards to next.
+func (r *Recorder) Wrap(next http.RoundTripper) http.RoundTripper {
+	r.next = next
+	return r
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := r.next.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	entry := harEntry{
+		started:         start,
+		StartedDateTime: start.Format(time.RFC3339Nano),
+		Time:            float64(elapsed.Microseconds()) / 1000,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Headers:     harHeaders(req.Header),
+			QueryString: harQuery(req),
+			Cookies:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+		Cache: struct{}{},
+		Timings: harTimings{
+			Send:    0,
+			Wait:    float64(elapsed.Microseconds()) / 1000,
+			Receive: 0,
+		},
+	}
+	if reqBody != nil {
+		entry.Request.PostData = &harPostData{
+			MimeType: req.Header.Get("Content-Type"),
+			Text:     string(reqBody),
+		}
+	}
+	if op, ok := operationFromContext(req.Context()); ok {
+		entry.OperationID = op.ID
+		entry.ClientID = op.ClientID
+		entry.API = op.API.String()
+	}
+
+	if err != nil {
+		entry.Response = harResponse{
+			Headers:     []harNameValue{},
+			Cookies:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		}
+		entry.Comment = err.Error()
+		r.add(entry)
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	entry.Response = harResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Headers:     harHeaders(resp.Header),
+		Cookies:     []harNameValue{},
+		Content: harContent{
+			Size:     len(respBody),
+			MimeType: resp.Header.Get("Content-Type"),
+			Text:     string(respBody),
+		},
+		HeadersSize: -1,
+		BodySize:    len(respBody),
+	}
+	// a round tripper returns either a response or an error, never both
+	if err != nil {
+		entry.Comment = err.Error()
+		r.add(entry)
+		return nil, err
+	}
+	r.add(entry)
+
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+func (r *Recorder) add(entry harEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+// WriteHAR writes all captured exchanges to the run in HAR 1.2 format.
+func (r *Recorder) WriteHAR(run *artifacts.Run) error {
+	r.mu.Lock()
+	entries := make([]harEntry, len(r.entries))
+	copy(entries, r.entries)
+	r.mu.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].started.Before(entries[j].started)
+	})
+
+	har := harFile{
+		Log: harLog{
+			Version: "1.2",
+			Creator: harCreator{Name: "durable-promise-test-harness", Version: "0.1.0"},
+			Entries: entries,
+		},
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(har); err != nil {
+		return err
+	}
+
+	return run.WriteString("exchanges.har", "raw http exchanges linked by operation id", b.String())
+}
+
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	headers := make([]harNameValue, 0, len(h))
+	for k, vs := range h {
+		for _, v := range vs {
+			if redacted[http.CanonicalHeaderKey(k)] {
+				v = "REDACTED"
+			}
+			headers = append(headers, harNameValue{Name: k, Value: v})
+		}
+	}
+	sort.Slice(headers, func(i, j int) bool {
+		return headers[i].Name < headers[j].Name
+	})
+	return headers
+}
+
+func harQuery(req *http.Request) []harNameValue {
+	query := make([]harNameValue, 0)
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			query = append(query, harNameValue{Name: k, Value: v})
+		}
+	}
+	sort.Slice(query, func(i, j int) bool {
+		return query[i].Name < query[j].Name
+	})
+	return query
+}
+
+//
+// har format, see http://www.softwareishard.com/blog/har-12-spec/
+//
+
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+
+	// custom fields are prefixed with an underscore as required by the spec
+	OperationID int    `json:"_operationId"`
+	ClientID    int    `json:"_clientId"`
+	API         string `json:"_api,omitempty"`
+
+	// started orders the entries, formatted times do not sort as strings
+	// since trailing zeros of the fraction are dropped
+	started time.Time
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	Cookies     []harNameValue `json:"cookies"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Cookies     []harNameValue `json:"cookies"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"math/rand"
 	"sync"
 	"time"
 
+	"github.com/resonatehq/durable-promise-test-harness/pkg/artifacts"
 	"github.com/resonatehq/durable-promise-test-harness/pkg/checker"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/metrics"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
 	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
 	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
 )
@@ -69,32 +73,137 @@ func (s *Simulation) Verify() error {
 		}
 	}()
 
+	run, err := artifacts.New(s.config.Out, s.config.RunName)
+	if err != nil {
+		return fmt.Errorf("error creating run directory: %v", err)
+	}
+	defer func() {
+		if err := run.WriteManifest(); err != nil {
+			log.Printf("error writing manifest: %v", err)
+		}
+		fmt.Printf("results written to %s\n", run.Dir)
+	}()
+
 	localStore := store.NewStore()
 
+	var m *metrics.Metrics
+	if s.config.MetricsAddr != "" {
+		m = metrics.New()
+		if err := m.Serve(s.config.MetricsAddr); err != nil {
+			return fmt.Errorf("error serving metrics: %v", err)
+		}
+		defer m.Shutdown()
+		localStore.AddListener(m)
+	}
+
+	var recorder *Recorder
+	if s.config.Capture {
+		recorder = NewRecorder()
+	}
+
+	opts, err := s.config.HTTP.ClientOptions(recorder)
+	if err != nil {
+		return err
+	}
+
+	var tracer *Tracer
+	if s.config.Tracing != nil {
+		tracer, err = NewTracer(context.Background(), s.config.Tracing, run)
+		if err != nil {
+			return fmt.Errorf("error setting up tracing: %v", err)
+		}
+		defer tracer.Shutdown(context.Background())
+		opts = append(opts, openapi.WithRequestEditorFn(tracer.Inject))
+	}
+
 	clients := make([]*Client, 0)
 	for i := 0; i < s.config.NumClients; i++ {
-		client, err := NewClient(i, s.config.Addr)
+		client, err := NewClient(i, s.config.Addr, opts...)
 		if err != nil {
 			return err
 		}
+		client.Tracer = tracer
 		clients = append(clients, client)
 	}
 
+	var initial checker.State
+	if s.config.Bootstrap && len(clients) > 0 {
+		promises, err := snapshot(context.Background(), clients[0], s.config.Namespace)
+		if err != nil {
+			return fmt.Errorf("error taking snapshot of namespace: %v", err)
+		}
+		initial = checker.NewState(promises)
+		fmt.Printf("model bootstrapped from %d promises in namespace '%s'\n", len(promises), s.config.Namespace)
+	} else if s.config.CheckNamespace && len(clients) > 0 {
+		n, err := existingPromises(context.Background(), clients[0], s.config.Namespace)
+		if err != nil {
+			log.Printf("warning: could not check namespace: %v", err)
+		} else if n > 0 {
+			log.Printf("warning: namespace '%s' already holds %d or more promises, the model starts empty so the run is likely to fail", s.config.Namespace, n)
+		}
+	}
+
+	var clock ClockControl
+	if s.config.ClockHook != "" {
+		clock, err = NewHTTPClockControl(s.config.ClockHook, s.config.HTTP)
+		if err != nil {
+			return fmt.Errorf("error setting up clock hook: %v", err)
+		}
+	}
+
+	// a controlled clock is not probed, every operation runs at a known time
+	var probes []store.Operation
+	if len(clients) > 0 && clock == nil {
+		probes = probeClock(context.Background(), clients[0], s.config.Namespace)
+		fmt.Printf("server clock offset %s\n", checker.EstimateClock(probes))
+	}
+
 	generator := NewGenerator(&GeneratorConfig{
-		r:           rand.New(rand.NewSource(0)),
-		numRequests: s.config.NumRequests,
-		Ids:         100,
-		Data:        100,
+		r:            rand.New(rand.NewSource(s.config.Seed)),
+		numRequests:  s.config.NumRequests,
+		Ids:          100,
+		Data:         100,
+		UniqueValues: s.config.UniqueValues,
+		Payload:      s.config.Payload,
+		Fuzz:         s.config.Fuzz,
+		Workload:     s.config.Workload,
+		Namespace:    s.config.Namespace,
+		Ticks:        s.config.Ticks,
 	})
 
 	checker := checker.NewChecker()
+	checker.Thresholds = s.config.Thresholds
+	if len(s.config.Engines) > 0 {
+		checker.Engines = s.config.Engines
+	}
+	if len(s.config.Models) > 0 {
+		checker.Models = s.config.Models
+	}
+	checker.Config = s.config.Describe()
+	if s.config.Bootstrap {
+		checker.Initial = initial
+		checker.Config["bootstrap"] = fmt.Sprintf("%d promises", len(initial))
+	}
+	checker.Probes = probes
+	checker.Config["run"] = run.Name
+	if m != nil {
+		checker.Progress = m
+	}
 
 	test := NewTestCase(
 		localStore,
 		clients,
 		generator,
 		checker,
+		run,
 	)
+	test.Recorder = recorder
+	if s.config.Rounds > 1 {
+		test.Rounds = s.config.Rounds
+	}
+	if clock != nil {
+		test.Clock, test.Ticks = clock, s.config.Ticks
+	}
 
 	if err := test.Run(); err != nil {
 		return err
@@ -108,14 +217,29 @@ type TestCase struct {
 	Clients   []*Client
 	Generator *Generator
 	Checker   *checker.Checker
+	Artifacts *artifacts.Run
+
+	// Rounds is the number of batches the clients run, every client waits
+	// for the others to finish a round before starting the next one.
+	Rounds int
+
+	// Clock is optional, when set every round is split into ticks and the
+	// server clock is moved forward between them.
+	Clock ClockControl
+	Ticks TickConfig
+
+	// Recorder is optional, when set the captured exchanges are written next to the results.
+	Recorder *Recorder
 }
 
-func NewTestCase(s *store.Store, cs []*Client, g *Generator, ch *checker.Checker) *TestCase {
+func NewTestCase(s *store.Store, cs []*Client, g *Generator, ch *checker.Checker, run *artifacts.Run) *TestCase {
 	return &TestCase{
 		Store:     s,
 		Clients:   cs,
 		Generator: g,
 		Checker:   ch,
+		Artifacts: run,
+		Rounds:    1,
 	}
 }
 
@@ -127,23 +251,80 @@ func (t *TestCase) Run() error {
 		t.Store.Run(results)
 	}()
 
-	var wg sync.WaitGroup
-	wg.Add(len(t.Clients))
+	runErr := t.run(ctx, results)
 
-	for _, c := range t.Clients {
-		ops := t.Generator.Generate(c.ID)
-		go func(client *Client, ops []store.Operation) {
-			defer wg.Done()
-			for _, op := range ops {
-				results <- client.Invoke(ctx, op)
-			}
-		}(c, ops)
+	close(results)
+	<-t.Store.Done
 
+	if runErr != nil {
+		return runErr
 	}
-	wg.Wait()
 
-	close(results)
-	<-t.Store.Done
+	checkErr := t.Checker.Check(t.Store.History(), t.Artifacts)
 
-	return t.Checker.Check(t.Store.History())
+	if t.Recorder != nil {
+		if err := t.Recorder.WriteHAR(t.Artifacts); err != nil {
+			return err
+		}
+	}
+
+	return checkErr
+}
+
+func (t *TestCase) run(ctx context.Context, results chan<- store.Operation) error {
+	ticks := 1
+	var start time.Time
+	if t.Clock != nil {
+		now, err := t.Clock.Now(ctx)
+		if err != nil {
+			return fmt.Errorf("error reading server clock: %v", err)
+		}
+		ticks, start = t.Ticks.Ticks, now
+	}
+
+	for round := 1; round <= t.Rounds; round++ {
+		if t.Rounds > 1 {
+			t.Generator.Round(round)
+		}
+		if t.Clock != nil {
+			t.Generator.Schedule(t.Ticks.at(start, round, 0))
+		}
+
+		ops := make([][]store.Operation, len(t.Clients))
+		for i, c := range t.Clients {
+			ops[i] = t.Generator.Generate(c.ID)
+		}
+
+		for tick := 0; tick < ticks; tick++ {
+			var at time.Time
+			if t.Clock != nil {
+				at = t.Ticks.at(start, round, tick)
+				if err := t.Clock.Set(ctx, at); err != nil {
+					return fmt.Errorf("error setting server clock: %v", err)
+				}
+			}
+
+			var wg sync.WaitGroup
+			wg.Add(len(t.Clients))
+
+			for i, c := range t.Clients {
+				go func(client *Client, ops []store.Operation) {
+					defer wg.Done()
+					for j, op := range ops {
+						if tickOf(j, len(ops), ticks) != tick {
+							continue
+						}
+						op.ServerTime = at
+						t.Store.Invoke(op)
+						results <- client.Invoke(ctx, op)
+					}
+				}(c, ops[i])
+			}
+
+			// barrier, the next tick or round starts once every client finished this one
+			wg.Wait()
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,161 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/artifacts"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/store"
+	"github.com/resonatehq/durable-promise-test-harness/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/resonatehq/durable-promise-test-harness"
+
+// TracingConfig selects where the span of every operation is exported to.
+type TracingConfig struct {
+	// Exporter is one of "otlp" or "file".
+	Exporter string
+	// Endpoint is the host:port of the otlp http collector.
+	Endpoint string
+	// Insecure disables tls when talking to the collector.
+	Insecure bool
+	// File is the path spans are written to as json by the file exporter,
+	// defaults to traces.json in the run directory.
+	File string
+}
+
+// Tracer creates a span for every operation and propagates its context to the server.
+type Tracer struct {
+	provider   *sdktrace.TracerProvider
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	file       *os.File
+}
+
+func NewTracer(ctx context.Context, config *TracingConfig, run *artifacts.Run) (*Tracer, error) {
+	t := &Tracer{propagator: propagation.TraceContext{}}
+
+	var exporter sdktrace.SpanExporter
+	switch config.Exporter {
+	case "otlp":
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Endpoint)}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		exporter = exp
+	case "file":
+		f, err := createTraceFile(config.File, run)
+		if err != nil {
+			return nil, err
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		t.file, exporter = f, exp
+	default:
+		return nil, fmt.Errorf("unknown trace exporter '%s', expected one of: otlp, file", config.Exporter)
+	}
+
+	t.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName("durable-promise-test-harness"))),
+	)
+	t.tracer = t.provider.Tracer(tracerName)
+
+	return t, nil
+}
+
+// Start opens the span of an operation and records its trace id on the operation.
+func (t *Tracer) Start(ctx context.Context, op *store.Operation) (context.Context, trace.Span) {
+	ctx, span := t.tracer.Start(ctx, op.API.String(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("harness.api", op.API.String()),
+			attribute.String("harness.promise_id", promiseID(*op)),
+			attribute.Int("harness.client_id", op.ClientID),
+			attribute.Int("harness.op_id", op.ID),
+			attribute.Int("harness.round", op.Round),
+		),
+	)
+	op.TraceID = span.SpanContext().TraceID().String()
+	return ctx, span
+}
+
+// End closes the span of an operation with the result of the operation.
+func (t *Tracer) End(span trace.Span, op store.Operation) {
+	span.SetAttributes(
+		attribute.String("harness.status", op.Status.String()),
+		attribute.Int("http.status_code", op.Code),
+	)
+	// expected failures such as a 404 are part of the api, only flag the unexpected ones
+	if op.Status == store.Invoke || op.Code >= 500 {
+		msg := "no response"
+		if errResp, ok := op.Output.(*openapi.ErrorResponse); ok && errResp != nil {
+			msg = errResp.Message
+		}
+		span.SetStatus(codes.Error, msg)
+	}
+	span.End()
+}
+
+// Inject is a request editor that adds the w3c trace context headers to every request.
+func (t *Tracer) Inject(ctx context.Context, req *http.Request) error {
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return nil
+}
+
+// Shutdown flushes all pending spans.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	err := t.provider.Shutdown(ctx)
+	if t.file != nil {
+		if cerr := t.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func createTraceFile(file string, run *artifacts.Run) (*os.File, error) {
+	if file == "" {
+		return run.Create("traces.json", "opentelemetry spans of every operation")
+	}
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(file)
+}
+
+// promiseID returns the id of the promise an operation targets, or the search pattern.
+func promiseID(op store.Operation) string {
+	switch v := op.Input.(type) {
+	case *openapi.SearchPromisesParams:
+		return utils.SafeDereference(v.Id)
+	case string:
+		return v
+	case *openapi.CreatePromiseJSONRequestBody:
+		return v.Id
+	case *openapi.CompletePromiseRequestWrapper:
+		return utils.SafeDereference(v.Id)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,97 @@
+package simulator
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/resonatehq/durable-promise-test-harness/pkg/openapi"
+)
+
+// ClientOptions builds the openapi client options so every operation is sent
+// with the configured credentials, headers and tls settings. If recorder is
+// not nil every exchange is captured on its way over the wire.
+func (c *HTTPConfig) ClientOptions(recorder *Recorder) ([]openapi.ClientOption, error) {
+	if c == nil {
+		c = &HTTPConfig{}
+	}
+
+	if c.BearerToken != "" && (c.Username != "" || c.Password != "") {
+		return nil, errors.New("basic auth and bearer token are mutually exclusive")
+	}
+
+	httpClient, err := c.httpClient(recorder)
+	if err != nil {
+		return nil, err
+	}
+
+	return []openapi.ClientOption{
+		openapi.WithHTTPClient(httpClient),
+		openapi.WithRequestEditorFn(c.editRequest),
+	}, nil
+}
+
+func (c *HTTPConfig) editRequest(_ context.Context, req *http.Request) error {
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+
+	switch {
+	case c.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	case c.Username != "" || c.Password != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	return nil
+}
+
+func (c *HTTPConfig) httpClient(recorder *Recorder) (*http.Client, error) {
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	if recorder != nil {
+		return &http.Client{Transport: recorder.Wrap(transport)}, nil
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+func (c *HTTPConfig) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CACert != "" {
+		pem, err := os.ReadFile(c.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ca bundle: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ca bundle '%s'", c.CACert)
+		}
+		config.RootCAs = pool
+	}
+
+	if c.ClientCert != "" || c.ClientKey != "" {
+		if c.ClientCert == "" || c.ClientKey == "" {
+			return nil, errors.New("client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,92 @@
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// WorkloadConfig shapes which promises operations target.
+type WorkloadConfig struct {
+	// Mode is one of "uniform", "hot-key", "race" or "lifecycle".
+	Mode string
+	// Skew is the exponent of the zipf distribution ids are drawn from in the
+	// hot-key workload, larger values concentrate operations on fewer ids.
+	Skew float64
+	// HotKeys is the number of ids every client contends on in each round of
+	// the race workload.
+	HotKeys int
+	// Stray is the probability a lifecycle step is replaced by an invalid
+	// transition, such as completing a promise twice.
+	Stray float64
+}
+
+const (
+	UniformWorkload   = "uniform"
+	HotKeyWorkload    = "hot-key"
+	RaceWorkload      = "race"
+	LifecycleWorkload = "lifecycle"
+
+	// DefaultSkew sends about a fifth of the operations to the hottest of a
+	// hundred ids.
+	DefaultSkew = 1.1
+
+	// DefaultHotKeys keeps a handful of ids under contention.
+	DefaultHotKeys = 3
+
+	// DefaultStray keeps most lifecycles on the successful path.
+	DefaultStray = 0.05
+)
+
+// Validate checks the mode is known and its parameters are sensible.
+func (c WorkloadConfig) Validate() error {
+	switch c.Mode {
+	case "", UniformWorkload, RaceWorkload, LifecycleWorkload:
+	case HotKeyWorkload:
+		// rand.NewZipf requires an exponent greater than one
+		if c.Skew <= 1 {
+			return fmt.Errorf("skew must be greater than 1, got %v", c.Skew)
+		}
+	default:
+		return fmt.Errorf("unknown workload '%s', expected one of: %s, %s, %s, %s", c.Mode, UniformWorkload, HotKeyWorkload, RaceWorkload, LifecycleWorkload)
+	}
+	if c.Mode == RaceWorkload && c.HotKeys < 1 {
+		return fmt.Errorf("hot keys must be at least 1, got %d", c.HotKeys)
+	}
+	if c.Mode == LifecycleWorkload && (c.Stray < 0 || c.Stray > 1) {
+		return fmt.Errorf("stray must be between 0 and 1, got %v", c.Stray)
+	}
+	return nil
+}
+
+// Enabled reports whether the workload differs from picking ids uniformly.
+func (c WorkloadConfig) Enabled() bool {
+	return c.Mode != "" && c.Mode != UniformWorkload
+}
+
+func (c WorkloadConfig) String() string {
+	switch c.Mode {
+	case HotKeyWorkload:
+		return fmt.Sprintf("%s skew %v", c.Mode, c.Skew)
+	case RaceWorkload:
+		return fmt.Sprintf("%s %d hot keys", c.Mode, c.HotKeys)
+	case LifecycleWorkload:
+		return fmt.Sprintf("%s stray %v", c.Mode, c.Stray)
+	default:
+		return UniformWorkload
+	}
+}
+
+// zipf returns the distribution hot-key ids are drawn from, or nil for other
+// workloads.
+func (c WorkloadConfig) zipf(r *rand.Rand, n int) *rand.Zipf {
+	if c.Mode != HotKeyWorkload || n < 2 {
+		return nil
+	}
+	return rand.NewZipf(r, c.Skew, 1, uint64(n-1))
+}
+
+// raceID names a hot key of a round of the race workload. Every round
+// contends on fresh promises so creates and completions keep racing.
+func raceID(round, key int) string {
+	return fmt.Sprintf("race-%d-%d", round, key)
+}
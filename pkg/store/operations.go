@@ -2,6 +2,7 @@ package store
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -56,6 +57,19 @@ func (a API) String() string {
 	}
 }
 
+// APIs lists every api in declaration order.
+var APIs = []API{Search, Get, Create, Cancel, Resolve, Reject}
+
+// ParseAPI returns the api with the given name, ignoring case.
+func ParseAPI(name string) (API, bool) {
+	for _, api := range APIs {
+		if strings.EqualFold(api.String(), name) {
+			return api, true
+		}
+	}
+	return 0, false
+}
+
 // Operation is an element of a history.
 type Operation struct {
 	ID          int
@@ -67,6 +81,40 @@ type Operation struct {
 	ReturnEvent time.Time
 	Status      Status
 	Code        int
+
+	// ServerDate is the Date header of the response, it is zero when the
+	// server sent none.
+	ServerDate time.Time
+
+	// ServerTime is the time the server clock was set to while the
+	// operation ran, it is zero unless the harness controls the clock.
+	ServerTime time.Time
+
+	// Round is the round of the operation in a run with several rounds, it
+	// is zero when the run is a single round.
+	Round int
+
+	// TraceID identifies the span of the operation when tracing is enabled.
+	TraceID string
+
+	// Fuzz is set on intentionally malformed or edge case inputs.
+	Fuzz *Fuzz
+
+	// RequestBytes and ResponseBytes are the sizes of the request and
+	// response as sent over the wire, headers included.
+	RequestBytes  int
+	ResponseBytes int
+}
+
+// Fuzz describes what is unusual about the input of an operation and how the
+// server may respond to it.
+type Fuzz struct {
+	Name string
+	// MayAccept is set for edge cases that are valid input, such as unicode
+	// ids. An accepted input is verified like any other operation.
+	MayAccept bool
+	// Reject lists the status codes that are correct when the input is rejected.
+	Reject []int
 }
 
 func (o Operation) String() string {
@@ -1,8 +1,15 @@
 package store
 
+// Listener is notified as operations start and as their results are stored.
+type Listener interface {
+	OnInvoke(op Operation)
+	OnComplete(op Operation)
+}
+
 type Store struct {
-	Done    chan struct{}
-	history []Operation
+	Done      chan struct{}
+	history   []Operation
+	listeners []Listener
 }
 
 func NewStore() *Store {
@@ -12,9 +19,24 @@ func NewStore() *Store {
 	}
 }
 
+// AddListener registers a listener, it must be called before Run.
+func (s *Store) AddListener(l Listener) {
+	s.listeners = append(s.listeners, l)
+}
+
+// Invoke records the start of an operation.
+func (s *Store) Invoke(op Operation) {
+	for _, l := range s.listeners {
+		l.OnInvoke(op)
+	}
+}
+
 func (s *Store) Run(results <-chan Operation) {
 	for op := range results {
 		s.history = append(s.history, op)
+		for _, l := range s.listeners {
+			l.OnComplete(op)
+		}
 	}
 
 	s.Done <- struct{}{}
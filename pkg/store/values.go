@@ -0,0 +1,45 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// valuePrefix marks values that encode the operation that wrote them.
+const valuePrefix = "op:"
+
+// WriteRef identifies the operation that wrote a value.
+type WriteRef struct {
+	OperationID int
+	ClientID    int
+}
+
+// EncodeValue returns a value that can be traced back to the operation that
+// wrote it, followed by an optional payload.
+func EncodeValue(opID, clientID int, payload []byte) []byte {
+	value := []byte(fmt.Sprintf("%s%d:client:%d:", valuePrefix, opID, clientID))
+	return append(value, payload...)
+}
+
+// DecodeValue returns the operation that wrote a value created by EncodeValue.
+func DecodeValue(value []byte) (WriteRef, bool) {
+	if !bytes.HasPrefix(value, []byte(valuePrefix)) {
+		return WriteRef{}, false
+	}
+
+	fields := bytes.SplitN(value[len(valuePrefix):], []byte(":"), 4)
+	if len(fields) < 4 || string(fields[1]) != "client" {
+		return WriteRef{}, false
+	}
+	opID, err := strconv.Atoi(string(fields[0]))
+	if err != nil {
+		return WriteRef{}, false
+	}
+	clientID, err := strconv.Atoi(string(fields[2]))
+	if err != nil {
+		return WriteRef{}, false
+	}
+
+	return WriteRef{OperationID: opID, ClientID: clientID}, true
+}
@@ -2,9 +2,9 @@ package utils
 
 import (
 	"net"
+	"net/url"
 	"os"
 	"path"
-	"strings"
 	"time"
 
 	"github.com/mohae/deepcopy"
@@ -51,7 +51,20 @@ func (g CommandGroups) Add(c *cobra.Command) {
 }
 
 func IsReady(Addr string) bool {
-	serverAddr := strings.TrimSuffix(strings.TrimPrefix(Addr, "http://"), "/")
+	u, err := url.Parse(Addr)
+	if err != nil {
+		return false
+	}
+
+	serverAddr := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		serverAddr = net.JoinHostPort(u.Hostname(), port)
+	}
+
 	conn, err := net.DialTimeout("tcp", serverAddr, 1*time.Second)
 	if err != nil {
 		return false